@@ -0,0 +1,237 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+ooooo
+  8
+  8   o   o   o .oPYo. .oPYo. odYo.
+  8   Y. .P. .P 8oooo8 8oooo8 8' `8
+  8   `b.d'b.d' 8.     8.     8   8
+  8    `Y' `Y'  `Yooo' `Yooo' 8   8
+::..::::..::..:::.....::.....:..::..
+::::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::::*/
+
+type (
+	// Отрезок времени анимации: начинается через delay после start, длится duration
+	Tween struct {
+		start           time.Time
+		delay, duration time.Duration
+	}
+	// Частица конфетти: доля ширины окна, скорость падения, размах качания, цвет
+	particle struct {
+		x, speed, sway float64
+		color          sdl.Color
+	}
+	// Наблюдатель анимации поверх поля: волна открытия, взрыв, победа
+	Animation struct {
+		board    *GameBoard
+		clock    func() time.Time
+		opening  map[int32]Tween
+		blasts   map[int32]Tween
+		confetti []particle
+		victory  Tween
+	}
+)
+
+// 0 до начала, 1 после конца, между ними доля прошедшего времени
+func (t Tween) Progress(now time.Time) float64 {
+	passed := now.Sub(t.start) - t.delay
+	if passed <= 0 {
+		return 0
+	}
+	if t.duration <= 0 || passed >= t.duration {
+		return 1
+	}
+	return float64(passed) / float64(t.duration)
+}
+
+func (t Tween) Started(now time.Time) bool {
+	return now.Sub(t.start) >= t.delay
+}
+
+func (t Tween) Done(now time.Time) bool {
+	return now.Sub(t.start) >= t.delay+t.duration
+}
+
+// Быстрый старт, плавная остановка
+func easeOut(p float64) float64 {
+	return 1 - (1-p)*(1-p)
+}
+
+/*
+.oo             o                o    o
+    .P 8                          8
+   .P  8 odYo. o8 ooYoYo. .oPYo. o8P o8 .oPYo. odYo.
+  oPooo8 8' `8  8 8' 8  8 .oooo8  8   8 8    8 8' `8
+ .P    8 8   8  8 8  8  8 8    8  8   8 8    8 8   8
+.P     8 8   8  8 8  8  8 `YooP8  8   8 `YooP' 8   8
+..:::::....::..:....:..:..:.....::..::..:.....:..::..
+:::::::::::::::::::::::::::::::::::::::::::::::::::::
+:::::::::::::::::::::::::::::::::::::::::::::::::::::*/
+
+// Шаг волны по настройке, 0 значит без анимации
+func animationStep() time.Duration {
+	switch config.Animation {
+	case "slow":
+		return 60 * time.Millisecond
+	case "normal":
+		return 30 * time.Millisecond
+	case "fast":
+		return 15 * time.Millisecond
+	}
+	return 0
+}
+
+func (s *Animation) New(board *GameBoard) {
+	s.board = board
+	s.Setup()
+}
+
+func (s *Animation) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
+}
+
+func (s *Animation) Setup() {
+	s.opening = map[int32]Tween{}
+	s.blasts = map[int32]Tween{}
+	s.confetti = nil
+}
+
+// Ячейки открываются волнами в порядке обхода в ширину, пока волна не дошла ячейка закрыта
+func (s *Animation) Cascade(waves map[int32]int32) {
+	step := animationStep()
+	if step == 0 || len(waves) < 2 {
+		return
+	}
+	now := s.now()
+	for idx, wave := range waves {
+		s.opening[idx] = Tween{start: now, delay: time.Duration(wave) * step, duration: step * 3}
+	}
+}
+
+// Мины вспыхивают по очереди, чем дальше от роковой, тем позже
+func (s *Animation) Explode(origin int32, mines []int32) {
+	step := animationStep()
+	if step == 0 || origin < 0 {
+		return
+	}
+	now, row := s.now(), s.board.gameBoardSize.row
+	for _, idx := range mines {
+		dx, dy := math.Abs(float64(idx%row-origin%row)), math.Abs(float64(idx/row-origin/row))
+		s.blasts[idx] = Tween{start: now, delay: time.Duration(math.Max(dx, dy)) * step * 2, duration: step * 10}
+	}
+}
+
+// Конфетти цветами палитры падают на все окно
+func (s *Animation) Victory() {
+	step := animationStep()
+	if step == 0 {
+		return
+	}
+	s.confetti = nil
+	for i := 0; i < 80; i++ {
+		s.confetti = append(s.confetti, particle{
+			x:     rand.Float64(),
+			speed: 0.7 + rand.Float64()*0.6,
+			sway:  rand.Float64() * float64(StatusLineHeight),
+			color: s.board.colors[1+rand.Intn(6)],
+		})
+	}
+	s.victory = Tween{start: s.now(), duration: step * 80}
+}
+
+func (s *Animation) Update(event Message) {
+	switch event.Kind() {
+	case NewGameEvent, ResetGameEvent, WindowResized:
+		s.Setup()
+	case FieldChangedEvent:
+		// задача дня, головоломка, редактор и -board приходят новым полем, прежняя анимация к нему не относится
+		if event.(FieldEvent).Fresh {
+			s.Setup()
+		}
+	case CellOpenedEvent, CascadeEvent, ChordOpenedEvent:
+		s.Cascade(event.(OpenEvent).Waves)
+	case ExplosionEvent:
+		blast := event.(BlastEvent)
+		s.Explode(blast.Origin, blast.Mines)
+	case VictoryEvent:
+		s.Victory()
+	case TickEvent:
+		now := s.now()
+		for idx, t := range s.opening {
+			if t.Done(now) {
+				delete(s.opening, idx)
+			}
+		}
+		for idx, t := range s.blasts {
+			if t.Done(now) {
+				delete(s.blasts, idx)
+			}
+		}
+		if s.confetti != nil && s.victory.Done(now) {
+			s.confetti = nil
+		}
+	}
+}
+
+// Прямоугольник, сжатый к центру до доли scale
+func scaleRect(rect *sdl.Rect, scale float64) *sdl.Rect {
+	w, h := int32(float64(rect.W)*scale), int32(float64(rect.H)*scale)
+	return &sdl.Rect{rect.X + (rect.W-w)/2, rect.Y + (rect.H-h)/2, w, h}
+}
+
+// На паузе поле закрыто заставкой, анимация под ней не рисуется
+func (s *Animation) Render(renderer *sdl.Renderer) {
+	if s.board.paused {
+		return
+	}
+	now := s.now()
+	closedBg, closedFg, blast := s.board.colors[8], s.board.colors[7], s.board.colors[3]
+	for idx, t := range s.opening {
+		rect := s.board.CellRect(idx)
+		if rect == nil {
+			continue
+		}
+		cover := scaleRect(rect, 1-easeOut(t.Progress(now)))
+		renderer.SetDrawColor(closedBg.R, closedBg.G, closedBg.B, closedBg.A)
+		renderer.FillRect(cover)
+		renderer.SetDrawColor(closedFg.R, closedFg.G, closedFg.B, closedFg.A)
+		renderer.DrawRect(cover)
+	}
+	renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	for idx, t := range s.blasts {
+		rect := s.board.CellRect(idx)
+		if rect == nil || !t.Started(now) {
+			continue
+		}
+		p := easeOut(t.Progress(now))
+		renderer.SetDrawColor(blast.R, blast.G, blast.B, uint8(255*(1-p)))
+		renderer.FillRect(scaleRect(rect, 0.3+p*1.2))
+	}
+	renderer.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+	if s.confetti != nil {
+		p := s.victory.Progress(now)
+		size := StatusLineHeight / 4
+		for i, c := range s.confetti {
+			x := c.x*float64(WinWidth) + math.Sin(p*20+float64(i))*c.sway
+			y := (p*c.speed*1.5 - 0.2) * float64(WinHeight)
+			renderer.SetDrawColor(c.color.R, c.color.G, c.color.B, c.color.A)
+			renderer.FillRect(&sdl.Rect{int32(x), int32(y), size, size})
+		}
+	}
+}
+
+func (s *Animation) Event(event sdl.Event) Message {
+	return NilEvent
+}
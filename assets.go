@@ -0,0 +1,162 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+.oo                           o
+    .P 8                       8
+   .P  8 .oPYo. .oPYo. .oPYo. o8P .oPYo.
+  oPooo8 Yb..   Yb..   8oooo8  8  Yb..
+ .P    8   'Yb.   'Yb. 8.      8    'Yb.
+.P     8 `YooP' `YooP' `Yooo'  8  `YooP'
+..:::::..:.....::.....::.....::..::.....:
+:::::::::::::::::::::::::::::::::::::::::
+:::::::::::::::::::::::::::::::::::::::::*/
+
+// Шрифт, темы и наборы головоломок вшиты в бинарник, звуки синтезируются,
+// файлы с теми же именами в каталоге пользователя заменяют и то и другое
+//
+//go:embed assets
+var embeddedAssets embed.FS
+
+const fontAsset = "Roboto-Regular.ttf"
+
+var (
+	assets   fs.FS = embeddedFS()
+	fontData []byte
+	// Шрифты прежних каталогов: открытые из них шрифты SDL читает до закрытия
+	oldFontData [][]byte
+)
+
+func embeddedFS() fs.FS {
+	sub, err := fs.Sub(embeddedAssets, "assets")
+	if err != nil {
+		panic(err) // каталог задан в go:embed выше, ошибка возможна только при сборке
+	}
+	return sub
+}
+
+// Каталог пользователя поверх встроенных файлов
+type overlayFS struct {
+	user, base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if f, err := o.user.Open(name); err == nil {
+		return f, nil
+	}
+	return o.base.Open(name)
+}
+
+// Содержимое каталога из обоих слоев, одинаковые имена один раз
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, baseErr := fs.ReadDir(o.base, name)
+	user, userErr := fs.ReadDir(o.user, name)
+	if baseErr != nil && userErr != nil {
+		return nil, baseErr
+	}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		seen[e.Name()] = true
+	}
+	for _, e := range user {
+		if !seen[e.Name()] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// $XDG_DATA_HOME/mines/assets, если в настройках каталог не задан
+func userAssetsDir() string {
+	if config.Assets != "" {
+		return config.Assets
+	}
+	if dir := dataDir(); dir != "" {
+		return filepath.Join(dir, "assets")
+	}
+	return ""
+}
+
+// Подключает каталог пользователя, темы и шрифт перечитываются при следующем обращении
+func setAssetsDir(dir string) {
+	assets = embeddedFS()
+	if dir != "" {
+		assets = overlayFS{user: os.DirFS(dir), base: assets}
+	}
+	palettes = nil
+	if fontData != nil {
+		oldFontData = append(oldFontData, fontData)
+		fontData = nil
+	}
+}
+
+func loadAsset(name string) ([]byte, error) {
+	return fs.ReadFile(assets, name)
+}
+
+// Шрифт читается из ресурсов один раз на каталог: SDL обращается к этой памяти все время, пока шрифт открыт
+func fontRW() (*sdl.RWops, error) {
+	if fontData == nil {
+		data, err := loadAsset(fontAsset)
+		if err != nil {
+			return nil, err
+		}
+		fontData = data
+	}
+	return sdl.RWFromMem(fontData)
+}
+
+// Тема: JSON со списком цветов #rrggbb в порядке Palette.colors
+func parseTheme(data []byte, name string) (Palette, error) {
+	var hex []string
+	if err := json.Unmarshal(data, &hex); err != nil {
+		return Palette{}, fmt.Errorf("theme %v: %w", name, err)
+	}
+	if len(hex) != len(defaultPalette.colors) {
+		return Palette{}, fmt.Errorf("theme %v: %v colors, want %v", name, len(hex), len(defaultPalette.colors))
+	}
+	p := Palette{name: name}
+	for _, h := range hex {
+		var c sdl.Color
+		if _, err := fmt.Sscanf(h, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil || len(h) != 7 {
+			return Palette{}, fmt.Errorf("theme %v: color %q", name, h)
+		}
+		c.A = 255
+		p.colors = append(p.colors, c)
+	}
+	return p, nil
+}
+
+// Темы из assets/themes по алфавиту, без единой темы остается классическая
+func loadPalettes() (list []Palette) {
+	names, _ := fs.Glob(assets, "themes/*.json")
+	for _, name := range names {
+		data, err := loadAsset(name)
+		if err == nil {
+			var p Palette
+			if p, err = parseTheme(data, strings.TrimSuffix(path.Base(name), ".json")); err == nil {
+				list = append(list, p)
+				continue
+			}
+		}
+		logUI.Warn("theme", "file", name, "err", err)
+	}
+	if len(list) == 0 {
+		list = append(list, defaultPalette)
+	}
+	return list
+}
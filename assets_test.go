@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbeddedAssets(t *testing.T) {
+	setAssetsDir("")
+	defer setAssetsDir("")
+	var names []string
+	for _, p := range allPalettes() {
+		names = append(names, p.name)
+	}
+	if len(names) != 4 || names[0] != "classic" {
+		t.Errorf("themes %v", names)
+	}
+	if got := getPalette("classic"); len(got) != len(defaultPalette.colors) || got[1] != defaultPalette.colors[1] {
+		t.Errorf("classic %v", got)
+	}
+	if packs := loadPuzzles(); len(packs) == 0 || packs[0].Name != "Basics" {
+		t.Errorf("puzzle packs %v", packs)
+	}
+	if data, err := loadAsset(fontAsset); err != nil || len(data) == 0 {
+		t.Errorf("font: %v", err)
+	}
+}
+
+func TestUserAssetsOverride(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dark := `["#101010", "#0000ff", "#008000", "#ff0000", "#000080", "#800000", "#008080", "#ffffff", "#202020"]`
+	write("themes/classic.json", dark)
+	write("themes/night.json", dark)
+	write("themes/broken.json", `["#101010"]`)
+	setAssetsDir(dir)
+	defer setAssetsDir("")
+	var names []string
+	for _, p := range allPalettes() {
+		names = append(names, p.name)
+	}
+	want := []string{"classic", "deuteranopia", "night", "protanopia", "tritanopia"}
+	if len(names) != len(want) {
+		t.Fatalf("themes %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("themes %v, want %v", names, want)
+		}
+	}
+	if got := getPalette("classic")[0]; got.R != 0x10 || got.A != 255 {
+		t.Errorf("classic is not replaced: %v", got)
+	}
+	if nextPalette("deuteranopia") != "night" {
+		t.Errorf("next after deuteranopia %v", nextPalette("deuteranopia"))
+	}
+	if data, err := loadAsset(fontAsset); err != nil || len(data) == 0 {
+		t.Errorf("built-in font under the user directory: %v", err)
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	for _, text := range []string{`{}`, `["#000000"]`, `["#00000", "#0000ff", "#008000", "#ff0000", "#000080", "#800000", "#008080", "#000000", "#808080"]`, `["000000", "#0000ff", "#008000", "#ff0000", "#000080", "#800000", "#008080", "#000000", "#808080"]`} {
+		if _, err := parseTheme([]byte(text), "bad"); err == nil {
+			t.Errorf("%v: no error", text)
+		}
+	}
+}
+
+func TestFontFollowsAssetsDir(t *testing.T) {
+	setAssetsDir("")
+	defer setAssetsDir("")
+	if _, err := fontRW(); err != nil {
+		t.Fatal(err)
+	}
+	embedded := fontData
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, fontAsset), []byte("user font"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setAssetsDir(dir)
+	if _, err := fontRW(); err != nil {
+		t.Fatal(err)
+	}
+	if string(fontData) != "user font" {
+		t.Errorf("font is not read from %v", dir)
+	}
+	if n := len(oldFontData); n == 0 || &oldFontData[n-1][0] != &embedded[0] {
+		t.Error("previous font memory is dropped while fonts may still use it")
+	}
+}
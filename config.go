@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+.oPYo.               o8o  o
+8    8                8
+8      .oPYo. odYo.  o8P o8 .oPYo.
+8      8    8 8' `8   8   8 8    8
+8    8 8    8 8   8   8   8 8    8
+`YooP' `YooP' 8   8   8   8 `YooP8
+:.....::.....:..::..:...::..:....8
+::::::::::::::::::::::::::::::ooP'.
+::::::::::::::::::::::::::::::...::*/
+
+type (
+	// Настройки запуска, хранятся в каталоге пользователя и перекрываются флагами
+	Config struct {
+		Preset     string `json:"preset"`
+		Row        int32  `json:"row"`
+		Column     int32  `json:"column"`
+		Mines      int32  `json:"mines"`
+		Seed       int64  `json:"seed"`
+		Theme      string `json:"theme"`
+		Glyphs     bool   `json:"glyphs"`
+		Fullscreen bool   `json:"fullscreen"`
+		Language   string `json:"language"`
+		Scale      int32  `json:"scale"`
+		Font       string `json:"font"`
+		Assets     string `json:"assets"`
+		Player     string `json:"player"`
+		// Правила и оформление из окна настроек
+		QuestionMarks bool   `json:"question_marks"`
+		FirstClick    string `json:"first_click"`
+		Chord         string `json:"chord"`
+		AutoFlag      bool   `json:"auto_flag"`
+		Sound         bool   `json:"sound"`
+		Volume        int    `json:"volume"`
+		Animation     string `json:"animation"`
+	}
+)
+
+// Уровни сложности как в классическом сапере
+var presets = map[string]boardConfig{
+	"beginner":     {row: 9, column: 9, mines: 10},
+	"intermediate": {row: 16, column: 16, mines: 40},
+	"expert":       {row: 30, column: 16, mines: 99},
+}
+
+var (
+	config     = defaultConfig()
+	configFile string
+)
+
+func defaultConfig() Config {
+	return Config{
+		Preset: "custom",
+		Row:    8,
+		Column: 8,
+		Mines:  10,
+		Theme:  "classic",
+		Scale:  2,
+
+		QuestionMarks: true,
+		FirstClick:    "cell",
+		Chord:         "left",
+		Sound:         true,
+		Volume:        80,
+		Animation:     "normal",
+	}
+}
+
+// $XDG_CONFIG_HOME/mines/config.json
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mines", "config.json")
+}
+
+func loadConfig(path string) (Config, error) {
+	c := defaultConfig()
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return c, err
+	}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return defaultConfig(), err
+	}
+	// прежнее значение по умолчанию, теперь этот шрифт встроен
+	if c.Font == "assets/Roboto-Regular.ttf" {
+		c.Font = ""
+	}
+	return c, nil
+}
+
+func (c Config) Save(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Записать изменения сделанные в игре
+func saveConfig() {
+	if err := config.Save(configFile); err != nil {
+		logUI.Error("config save", "err", err)
+	}
+}
+
+func (c Config) BoardSize() boardConfig {
+	return boardConfig{row: c.Row, column: c.Column, mines: c.Mines, minesPercent: c.Mines * 100 / (c.Row * c.Column)}
+}
+
+// Запомнить размер поля, совпадающий с уровнем сложности получает его имя
+func (c *Config) SetBoardSize(b boardConfig) {
+	c.Row, c.Column, c.Mines = b.row, b.column, b.mines
+	c.Preset = presetName(b)
+}
+
+// Читает файл настроек и применяет поверх него флаги командной строки
+func parseFlags(args []string) error {
+	var err error
+	fset := flag.NewFlagSet("mines", flag.ContinueOnError)
+	fset.StringVar(&configFile, "config", defaultConfigPath(), "config file")
+	// файл настроек нужен раньше остальных флагов, их значения по умолчанию берутся из него
+	for i, arg := range args {
+		if arg == "-config" || arg == "--config" {
+			if i+1 < len(args) {
+				configFile = args[i+1]
+			}
+		} else if v, ok := strings.CutPrefix(strings.TrimPrefix(arg, "-"), "-config="); ok {
+			configFile = v
+		}
+	}
+	if config, err = loadConfig(configFile); err != nil {
+		logUI.Warn("config load", "err", err)
+	}
+	c := &config
+	var row, column, mines int
+	fset.StringVar(&c.Preset, "preset", c.Preset, "difficulty: beginner, intermediate, expert, custom")
+	fset.IntVar(&row, "row", int(c.Row), "cells in a row")
+	fset.IntVar(&column, "column", int(c.Column), "cells in a column")
+	fset.IntVar(&mines, "mines", int(c.Mines), "mines on the board")
+	fset.Int64Var(&c.Seed, "seed", c.Seed, "random seed, 0 for a new board every game")
+	fset.StringVar(&c.Theme, "theme", c.Theme, "palette: classic, deuteranopia, protanopia, tritanopia")
+	fset.BoolVar(&c.Glyphs, "glyphs", c.Glyphs, "draw glyph shapes on numbers and marks")
+	fset.BoolVar(&c.Fullscreen, "fullscreen", c.Fullscreen, "start in fullscreen")
+	fset.StringVar(&c.Language, "lang", c.Language, "interface language: ru, en, empty for environment")
+	scale := fset.Int("scale", int(c.Scale), "window size in 320x180 units")
+	fset.StringVar(&c.Font, "font", c.Font, "font file, empty for the built-in Roboto")
+	fset.StringVar(&c.Assets, "assets", c.Assets, "directory with fonts, themes, sounds and puzzles replacing the built-in ones")
+	fset.StringVar(&c.Player, "player", c.Player, "player name for statistics, empty for $USER")
+	fset.BoolVar(&startDaily, "daily", false, "start with today's daily board")
+	fset.StringVar(&boardFile, "board", "", "start with a mine layout from a .avf, .rawvf or */. map file")
+	logSpec := fset.String("log", os.Getenv("MINES_LOG"), "log categories engine, ui, input or all, with =level (debug, info, warn, error, off) and json, e.g. engine=info,input,json; off by default")
+	if err = fset.Parse(args); err != nil {
+		return err
+	}
+	if err = setupLogging(*logSpec, os.Stderr); err != nil {
+		fmt.Fprintln(fset.Output(), err)
+		return err
+	}
+	c.Row, c.Column, c.Mines, c.Scale = int32(row), int32(column), int32(mines), int32(*scale)
+	set := map[string]bool{}
+	fset.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if p, ok := presets[c.Preset]; ok && set["preset"] {
+		c.Row, c.Column, c.Mines = p.row, p.column, p.mines
+	}
+	if set["row"] || set["column"] || set["mines"] {
+		c.SetBoardSize(boardConfig{row: int32(row), column: int32(column), mines: int32(mines)})
+	}
+	c.clamp()
+	applyConfig()
+	return nil
+}
+
+// Значения за пределами поля заменяются ближайшими допустимыми
+func (c *Config) clamp() {
+	c.Row = clamp(c.Row, minRow, maxRow)
+	c.Column = clamp(c.Column, minColumn, maxColumn)
+	c.Mines = clamp(c.Mines, minMines, c.Row*c.Column-1)
+	if c.Scale < 1 {
+		c.Scale = 1
+	}
+}
+
+func clamp(value, min, max int32) int32 {
+	if value < min {
+		return min
+	} else if value > max {
+		return max
+	}
+	return value
+}
+
+// Переносит настройки в глобальные переменные окна и поля
+func applyConfig() {
+	mn = config.Scale
+	WinWidth, WinHeight = 320*mn, 180*mn
+	StatusLineHeight = WinHeight / 20
+	StatusLineFontSize = int(StatusLineHeight) - 3
+	fontPath = config.Font
+	setAssetsDir(userAssetsDir())
+	applyLocale()
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+.oPYo.              o                8 8
+8    8               8               8 8
+8      .oPYo. odYo. o8P oPYo. .oPYo. 8 8 .oPYo. oPYo.
+8      8    8 8' `8  8  8  `' 8    8 8 8 8oooo8 8  `'
+8    8 8    8 8   8  8  8     8    8 8 8 8.     8
+`YooP' `YooP' 8   8  8  8     `YooP' 8 8 `Yooo' 8
+:.....::.....:..::..:..:..:::::.....:....:.....:..::::
+::::::::::::::::::::::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::::::::::::::::::::::*/
+
+type (
+	// Правила игры без окна: меняет модель и публикует изменения в шину,
+	// рисуют их наблюдатели SDL или любой другой интерфейс
+	GameController struct {
+		mines      *Mines
+		timer      Timer
+		stats      *Stats
+		daily      *Leaderboard
+		progress   *PuzzleProgress
+		puzzle     *Puzzle
+		puzzleName string
+		editor     *Editor
+		dailyDate  string
+		dailyStart int32
+		timerText  string
+	}
+)
+
+// Хранилища могут быть nil, тогда результаты никуда не записываются
+func NewGameController(mines *Mines, stats *Stats, daily *Leaderboard, progress *PuzzleProgress) *GameController {
+	c := &GameController{mines: mines, stats: stats, daily: daily, progress: progress, dailyStart: -1}
+	c.timer.Reset()
+	return c
+}
+
+func (c *GameController) Field() *Field {
+	return &c.mines.field
+}
+
+func (c *GameController) State() minesStateType {
+	return c.mines.field.GetState()
+}
+
+// Поле целиком для интерфейса, fresh значит новое поле вместо прежнего
+func (c *GameController) publishField(fresh bool) {
+	if fresh {
+		c.timerText = ""
+	}
+	c.mines.bus.Publish(FieldEvent{FieldChangedEvent, c.mines.field.GetFieldValues(), c.mines.field.GetStatistic(), c.mines.field.boardSize, c.mines.field.targets, fresh})
+}
+
+// Новое поле по зерну из настроек: задача дня, головоломка и -board ставят свое
+func (c *GameController) NewGame(size boardConfig) {
+	c.mines.field.New(size)
+	c.mines.field.SetSeed(config.Seed)
+	c.mines.field.SetState(gameStart)
+	c.dailyDate, c.puzzle, c.editor = "", nil, nil
+	c.timer.Reset()
+	c.publishField(true)
+}
+
+// Та же расстановка заново, головоломка и задача дня начинаются с начала
+func (c *GameController) Reset() {
+	if c.puzzle != nil {
+		c.startPuzzle()
+		return
+	}
+	state := c.State()
+	c.mines.field.Reset()
+	if state == gameStart {
+		// мины еще не расставлены: снимаются только флаги, поле ждет первого хода
+		c.mines.field.SetState(gameStart)
+	}
+	c.timer.Reset()
+	if c.dailyDate != "" {
+		c.openDaily()
+	}
+	c.publishField(true)
+}
+
+func (c *GameController) StartPuzzle(p *Puzzle, key string) {
+	c.puzzle, c.puzzleName = p, key
+	c.startPuzzle()
+}
+
+func (c *GameController) startPuzzle() {
+	c.dailyDate, c.editor = "", nil
+	c.mines.field = c.puzzle.Field()
+	c.timer.Reset()
+	c.publishField(true)
+}
+
+// Задача дня на дату YYYY-MM-DD
+func (c *GameController) Daily(date string) {
+	c.puzzle, c.editor = nil, nil
+	c.dailyDate = date
+	c.mines.field, c.dailyStart = dailyField(date)
+	c.timer.Reset()
+	c.openDaily()
+	c.publishField(true)
+}
+
+// Ежедневное поле открывается со стартовой ячейки, время идет с первого хода
+func (c *GameController) openDaily() {
+	pos, _ := c.mines.field.getPosOfCell(c.dailyStart)
+	c.mines.field.Open(pos.X, pos.Y)
+	c.mines.field.TakeWaves()
+}
+
+// Редактор открывается на текущей головоломке или на пустом поле size, повторный вызов играет поле
+func (c *GameController) ToggleEditor(size boardConfig) {
+	if c.editor != nil {
+		c.StartPuzzle(c.editor.puzzle, "")
+		return
+	}
+	c.editor = &Editor{}
+	c.editor.New(size, c.puzzle)
+	c.mines.field.New(boardConfig{row: c.editor.puzzle.Row, column: c.editor.puzzle.Column})
+	c.dailyDate = ""
+	c.timer.Reset()
+	c.showEditor(true)
+}
+
+// Редактор показывает мины, цифры открытых ячеек, 3BV и решаемость после каждой правки
+func (c *GameController) showEditor(fresh bool) {
+	size := boardConfig{row: c.editor.puzzle.Row, column: c.editor.puzzle.Column, mines: c.editor.puzzle.Mines()}
+	c.mines.bus.Publish(FieldEvent{FieldChangedEvent, c.editor.Values(), []int{int(size.mines), 0, 0}, size, c.editor.Targets(), fresh})
+	bbbv, err := c.editor.Report()
+	solvable := tr("editor_solvable")
+	switch {
+	case errors.Is(err, errNoOpened):
+		solvable = tr("editor_no_opened")
+	case err != nil:
+		solvable = tr("editor_guess")
+		logEngine.Error("editor", "err", err)
+	}
+	c.mines.bus.Publish(InfoEvent{InfoChangedEvent, fmt.Sprintf(tr("editor_3bv"), bbbv), solvable})
+}
+
+func (c *GameController) SaveEditor() {
+	if c.editor == nil {
+		return
+	}
+	path, err := c.editor.Save(userPuzzlesDir())
+	if err != nil {
+		logEngine.Error("editor save", "err", err)
+		c.mines.bus.Publish(InfoEvent{InfoChangedEvent, tr("editor_save_error"), err.Error()})
+		return
+	}
+	logEngine.Info("editor saved", "path", path)
+	c.mines.bus.Publish(InfoEvent{InfoChangedEvent, tr("editor_saved"), filepath.Base(path)}, PuzzlesChangedEvent)
+}
+
+// Расстановка в dir картой мин и RawVF с настройкой вопросов, есть только после первого хода
+func (c *GameController) Export(dir string) (paths []string, err error) {
+	if c.editor != nil || c.State() == gameStart {
+		return nil, nil
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	name := filepath.Join(dir, time.Now().Format("20060102-150405"))
+	layout := c.mines.field.GetLayout()
+	layout.marks, layout.marksKnown = config.QuestionMarks, true
+	for _, ext := range []string{".txt", ".rawvf"} {
+		if err = ExportLayout(name+ext, layout); err != nil {
+			return paths, err
+		}
+		paths = append(paths, name+ext)
+	}
+	return paths, nil
+}
+
+// Левая кнопка: первый ход расставляет мины, дальше открытие или аккорд по открытой ячейке
+func (c *GameController) Click(idx int32) {
+	field := &c.mines.field
+	if c.editor != nil {
+		c.editor.ToggleMine(idx)
+		c.showEditor(false)
+		return
+	}
+	switch field.GetState() {
+	case gameStart:
+		pos, cell := field.getPosOfCell(idx)
+		if cell.IsFlagged() {
+			return
+		}
+		field.SetFirstClick(config.FirstClick)
+		field.Setup(idx)
+		c.timer.Start()
+		field.saveView()
+		field.Open(pos.X, pos.Y)
+		field.CountClick(leftClick, true)
+		c.opened(0)
+	case gamePlay:
+		pos, cell := field.getPosOfCell(idx)
+		c.timer.Start()
+		field.saveView()
+		count, useful := field.openedCount(), false
+		if cell.IsClosed() {
+			field.Open(pos.X, pos.Y)
+		} else if cell.IsOpened() {
+			if config.AutoFlag {
+				useful = field.AutoFlag(pos.X, pos.Y)
+			}
+			if config.Chord == "left" {
+				useful = field.Chord(pos.X, pos.Y) || useful
+			}
+		}
+		field.CountClick(leftClick, useful || count != field.openedCount())
+		c.opened(count)
+	default:
+		return
+	}
+	c.finish()
+	c.publishField(false)
+}
+
+// Правая кнопка: флаг или вопрос, в редакторе закрыто -> открыто -> цель
+func (c *GameController) Flag(idx int32) {
+	field := &c.mines.field
+	if c.editor != nil {
+		c.editor.CycleReveal(idx)
+		c.showEditor(false)
+		return
+	}
+	if state := field.GetState(); state != gamePlay && state != gameStart || !field.isCell(idx) {
+		return
+	}
+	field.SetQuestionMarks(config.QuestionMarks)
+	_, cell := field.getPosOfCell(idx)
+	field.CountClick(rightClick, !cell.IsOpened())
+	if !cell.IsOpened() {
+		c.mines.bus.Publish(FlagChangedEvent)
+	}
+	field.MarkFlag(idx)
+	// головоломка выигрывается флагом на последней цели-мине
+	c.finish()
+	c.publishField(false)
+}
+
+func (c *GameController) Chord(idx int32) {
+	field := &c.mines.field
+	if field.GetState() != gamePlay {
+		return
+	}
+	pos, _ := field.getPosOfCell(idx)
+	field.saveView()
+	useful := false
+	if config.AutoFlag {
+		useful = field.AutoFlag(pos.X, pos.Y)
+	}
+	useful = field.Chord(pos.X, pos.Y) || useful
+	field.CountClick(chordClick, useful)
+	waves := field.TakeWaves()
+	if useful && field.GetState() != gameOver {
+		c.mines.bus.Publish(OpenEvent{ChordOpenedEvent, waves})
+	}
+	c.finish()
+	c.publishField(false)
+}
+
+// Звук и анимация открытия: одна ячейка или целая область
+func (c *GameController) opened(before int32) {
+	waves := c.mines.field.TakeWaves()
+	if c.mines.field.GetState() == gameOver {
+		return
+	}
+	if count := c.mines.field.openedCount() - before; count > 1 {
+		c.mines.bus.Publish(OpenEvent{CascadeEvent, waves})
+	} else if count == 1 {
+		c.mines.bus.Publish(OpenEvent{CellOpenedEvent, waves})
+	}
+}
+
+// Конец партии: остановить время, записать результат, показать итоги
+func (c *GameController) finish() {
+	field := &c.mines.field
+	if !field.isWin() && !field.isGameOver() {
+		return
+	}
+	c.timer.Stop()
+	won := field.GetState() == gameWin
+	bbbv, _ := field.Get3BV()
+	left, right, chord, _ := field.GetClicks()
+	if c.puzzle != nil {
+		if won && c.puzzleName != "" && c.progress != nil {
+			c.progress.SetSolved(c.puzzleName)
+		}
+	} else if c.stats != nil {
+		c.stats.Record(playerName(), GameRecord{
+			Date:   time.Now(),
+			Preset: presetName(field.boardSize),
+			Won:    won,
+			Time:   c.timer.Seconds(),
+			BBBV:   bbbv,
+			Clicks: left + right + chord,
+		})
+	}
+	if won {
+		c.mines.bus.Publish(VictoryEvent)
+	} else {
+		origin, mines := field.mineCells()
+		c.mines.bus.Publish(BlastEvent{ExplosionEvent, origin, mines})
+	}
+	summary := c.summary(c.timer.Seconds())
+	if c.dailyDate != "" && c.daily != nil {
+		c.daily.Record(DailyResult{
+			Date:   c.dailyDate,
+			Player: playerName(),
+			Won:    won,
+			Time:   c.timer.Seconds(),
+			BBBV:   bbbv,
+			Clicks: left + right + chord,
+		})
+		place, total := c.daily.Place(c.dailyDate, playerName())
+		summary = append(summary, fmt.Sprintf(tr("daily_place"), c.dailyDate, place, total))
+	}
+	c.mines.bus.Publish(OutcomeEvent{GameEndEvent, field.GetState(), c.timer.Seconds(), bbbv, c.timer.Precise(), summary})
+}
+
+// Итоги партии для окна сообщения: время, 3BV, нажатия и эффективность, после проигрыша разбор
+func (c *GameController) summary(seconds float64) (lines []string) {
+	field := &c.mines.field
+	total, solved := field.Get3BV()
+	left, right, chord, wasted := field.GetClicks()
+	var bbbvs, efficiency float64
+	if seconds > 0 {
+		bbbvs = float64(solved) / seconds
+	}
+	if clicks := left + right + chord; clicks > 0 {
+		efficiency = float64(solved) * 100 / float64(clicks)
+	}
+	lines = append(lines,
+		fmt.Sprintf(tr("time_bbbv"), seconds, solved, total, bbbvs),
+		fmt.Sprintf(tr("clicks"), left+right+chord, left, right, chord, wasted),
+		fmt.Sprintf(tr("efficiency"), efficiency))
+	if field.GetState() == gameOver {
+		right, wrong := field.FlagsSummary()
+		lines = append(lines, fmt.Sprintf(tr("flags_summary"), right, wrong), tr(field.AnalyzeLoss()))
+	}
+	return lines
+}
+
+// Пауза только из игры, продолжение только с паузы
+func (c *GameController) SetPause(on bool) {
+	if state := c.State(); on && state == gamePlay {
+		c.timer.Pause()
+		c.mines.field.SetState(gamePause)
+	} else if !on && state == gamePause {
+		c.timer.Resume()
+		c.mines.field.SetState(gamePlay)
+	} else {
+		return
+	}
+	c.publishField(false)
+}
+
+func (c *GameController) TogglePause() {
+	c.SetPause(c.State() == gamePlay)
+}
+
+// Время на часах, публикуется только когда меняется надпись
+func (c *GameController) Tick() {
+	if c.editor != nil || (c.timer.IsPause() && c.timer.started) {
+		return
+	}
+	_, arr := c.timer.GetTimer()
+	if text := fmt.Sprint(arr); text != c.timerText {
+		c.timerText = text
+		c.mines.bus.Publish(TimerEvent{TimerChangedEvent, arr})
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Слой, который только запоминает все сообщения шины
+type recorder struct {
+	got []Message
+}
+
+func (r *recorder) Setup()                  {}
+func (r *recorder) Update(msg Message)      { r.got = append(r.got, msg) }
+func (r *recorder) Render(*sdl.Renderer)    {}
+func (r *recorder) Event(sdl.Event) Message { return NilEvent }
+
+// Раздать очередь шины и забрать накопленные сообщения
+func (r *recorder) take(m *Mines) (got []Message) {
+	m.bus.Dispatch()
+	got, r.got = r.got, nil
+	return got
+}
+
+// Последнее сообщение вида kind или nil
+func last(messages []Message, kind Event) Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Kind() == kind {
+			return messages[i]
+		}
+	}
+	return nil
+}
+
+func newTestController(t *testing.T) (*GameController, *Mines, *recorder) {
+	t.Helper()
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = defaultConfig()
+	m := &Mines{}
+	rec := &recorder{}
+	m.bus.Attach(rec)
+	return NewGameController(m, nil, nil, nil), m, rec
+}
+
+func testPuzzle(t *testing.T, rows ...string) *Puzzle {
+	t.Helper()
+	pack, err := ParsePuzzlePack(strings.NewReader("puzzle test\n"+strings.Join(rows, "\n")), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pack.Puzzles[0]
+}
+
+func TestFlagOnLastTargetWinsPuzzle(t *testing.T) {
+	c, m, rec := newTestController(t)
+	c.StartPuzzle(testPuzzle(t,
+		"!?..",
+		"oooo",
+		"oooo"), "")
+	rec.take(m)
+	c.Click(1)
+	if got := rec.take(m); c.State() != gamePlay || last(got, GameEndEvent) != nil {
+		t.Fatalf("safe target opened: state %v", c.State())
+	}
+	c.Flag(0)
+	got := rec.take(m)
+	if c.State() != gameWin {
+		t.Fatalf("state %v after the last target flag, want win", c.State())
+	}
+	if last(got, VictoryEvent) == nil {
+		t.Error("no victory event")
+	}
+	if outcome, ok := last(got, GameEndEvent).(OutcomeEvent); !ok || outcome.State != gameWin {
+		t.Errorf("outcome %v", last(got, GameEndEvent))
+	}
+	if !c.timer.IsPause() {
+		t.Error("timer runs after the win")
+	}
+}
+
+// Раскладка после первого хода в середину поля
+func playedLayout(c *GameController, size boardConfig) Layout {
+	c.NewGame(size)
+	c.Click(size.column/2*size.row + size.row/2)
+	return c.Field().GetLayout()
+}
+
+func sameLayout(a, b Layout) bool {
+	for idx := range a.mines {
+		if a.mines[idx] != b.mines[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewGameAfterDailyIsRandom(t *testing.T) {
+	c, _, _ := newTestController(t)
+	size := presets["intermediate"]
+	c.Daily("2024-05-01")
+	daily := c.Field().GetLayout()
+	a, b := playedLayout(c, size), playedLayout(c, size)
+	if sameLayout(a, b) || sameLayout(a, daily) {
+		t.Error("new games repeat the daily board")
+	}
+	config.Seed = 11
+	if a, b := playedLayout(c, size), playedLayout(c, size); !sameLayout(a, b) {
+		t.Error("seed from the config gives different boards")
+	}
+}
+
+// Партия на готовой карте мин, как будто первый ход уже сделан
+func playMineMap(t *testing.T, c *GameController, rows ...string) {
+	t.Helper()
+	c.mines.field = *mineMap(t, rows...)
+	c.timer.Reset()
+}
+
+func TestControllerNewGame(t *testing.T) {
+	c, m, rec := newTestController(t)
+	size := presets["beginner"]
+	c.NewGame(size)
+	field, ok := last(rec.take(m), FieldChangedEvent).(FieldEvent)
+	if !ok || !field.Fresh || field.Size != size || c.State() != gameStart {
+		t.Fatalf("new game event %+v, state %v", field, c.State())
+	}
+	for idx, value := range field.Values {
+		if value != closed {
+			t.Fatalf("cell %v is %v on a new board", idx, value)
+		}
+	}
+	c.Click(0)
+	got := rec.take(m)
+	field, ok = last(got, FieldChangedEvent).(FieldEvent)
+	if !ok || field.Fresh || c.State() != gamePlay || field.Values[0] == closed {
+		t.Fatalf("first click event %+v, state %v", field, c.State())
+	}
+	if last(got, CellOpenedEvent) == nil && last(got, CascadeEvent) == nil {
+		t.Error("no open event after the first click")
+	}
+	if c.timer.IsPause() {
+		t.Error("timer does not run after the first click")
+	}
+}
+
+func TestControllerChordWins(t *testing.T) {
+	c, m, rec := newTestController(t)
+	playMineMap(t, c,
+		"*...",
+		"....",
+		"...*")
+	c.Click(3)
+	if got := rec.take(m); last(got, CascadeEvent) == nil || c.State() != gamePlay {
+		t.Fatalf("cascade from the corner: state %v", c.State())
+	}
+	c.Flag(-1)
+	c.Flag(12)
+	if got := rec.take(m); len(got) != 0 {
+		t.Errorf("flags outside the board publish %v", got)
+	}
+	c.Flag(0)
+	got := rec.take(m)
+	if field, ok := last(got, FieldChangedEvent).(FieldEvent); !ok || field.Values[0] != flagged {
+		t.Fatalf("flag event %+v", last(got, FieldChangedEvent))
+	}
+	if last(got, FlagChangedEvent) == nil {
+		t.Error("no flag sound event")
+	}
+	c.Chord(5)
+	got = rec.take(m)
+	if c.State() != gameWin {
+		t.Fatalf("state %v after the chord, want win", c.State())
+	}
+	if outcome, ok := last(got, GameEndEvent).(OutcomeEvent); !ok || outcome.State != gameWin || len(outcome.Summary) == 0 {
+		t.Errorf("outcome %+v", last(got, GameEndEvent))
+	}
+	c.Chord(5)
+	if got := rec.take(m); len(got) != 0 {
+		t.Errorf("chord after the win publishes %v", got)
+	}
+}
+
+func TestControllerLoss(t *testing.T) {
+	c, m, rec := newTestController(t)
+	playMineMap(t, c,
+		"*...",
+		"....",
+		"...*")
+	c.Click(3)
+	rec.take(m)
+	c.Click(11)
+	got := rec.take(m)
+	if c.State() != gameOver {
+		t.Fatalf("state %v after a mine, want game over", c.State())
+	}
+	if blast, ok := last(got, ExplosionEvent).(BlastEvent); !ok || blast.Origin != 11 || len(blast.Mines) != 2 {
+		t.Errorf("blast %+v", last(got, ExplosionEvent))
+	}
+	if outcome, ok := last(got, GameEndEvent).(OutcomeEvent); !ok || outcome.State != gameOver {
+		t.Errorf("outcome %+v", last(got, GameEndEvent))
+	}
+	if field, ok := last(got, FieldChangedEvent).(FieldEvent); !ok || field.Values[11] == closed {
+		t.Errorf("mines are not shown: %+v", last(got, FieldChangedEvent))
+	}
+}
+
+func TestControllerPauseAndTick(t *testing.T) {
+	c, m, rec := newTestController(t)
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	c.timer.clock = clock.now
+	playMineMap(t, c,
+		"*...",
+		"....",
+		"...*")
+	c.Tick()
+	if timer, ok := last(rec.take(m), TimerChangedEvent).(TimerEvent); !ok || timer.Timer[0] != 0 {
+		t.Fatalf("first tick %+v", timer)
+	}
+	c.Tick()
+	if got := rec.take(m); len(got) != 0 {
+		t.Errorf("same time published again: %v", got)
+	}
+	c.Click(3)
+	rec.take(m)
+	clock.advance(2 * time.Second)
+	c.Tick()
+	if timer, ok := last(rec.take(m), TimerChangedEvent).(TimerEvent); !ok || timer.Timer[0] != 2 {
+		t.Fatalf("tick after 2s %+v", timer)
+	}
+	c.TogglePause()
+	if field, ok := last(rec.take(m), FieldChangedEvent).(FieldEvent); !ok || c.State() != gamePause {
+		t.Fatalf("pause event %+v, state %v", field, c.State())
+	}
+	clock.advance(time.Minute)
+	c.Tick()
+	if got := rec.take(m); len(got) != 0 {
+		t.Errorf("paused clock publishes %v", got)
+	}
+	c.Click(6)
+	if c.State() != gamePause {
+		t.Errorf("click on a paused board: state %v", c.State())
+	}
+	c.TogglePause()
+	clock.advance(time.Second)
+	c.Tick()
+	got := rec.take(m)
+	if c.State() != gamePlay || last(got, FieldChangedEvent) == nil {
+		t.Fatalf("resume: state %v, events %v", c.State(), got)
+	}
+	if timer, ok := last(got, TimerChangedEvent).(TimerEvent); !ok || timer.Timer[0] != 3 {
+		t.Errorf("tick after the pause %+v, want 3s", timer)
+	}
+}
+
+func TestResetBeforeFirstClick(t *testing.T) {
+	c, m, rec := newTestController(t)
+	size := presets["beginner"]
+	c.NewGame(size)
+	c.Flag(5)
+	c.Reset()
+	field, ok := last(rec.take(m), FieldChangedEvent).(FieldEvent)
+	if !ok || !field.Fresh || c.State() != gameStart || field.Values[5] != closed {
+		t.Fatalf("reset before the first click: state %v, event %+v", c.State(), field)
+	}
+	c.Click(0)
+	if got := countMines(c.Field()); got != size.mines || c.State() != gamePlay {
+		t.Errorf("first click after reset: %v mines, state %v", got, c.State())
+	}
+}
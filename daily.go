@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+/*
+.oPYo.          o 8
+ 8    8           8
+o8    8 .oPYo. o8 8 o    o
+ 8    8 .oooo8  8 8 8    8
+ 8    8 8    8  8 8 8    8
+ 8oooP' `YooP8  8 8 `YooP8
+:......::.....::....:....8
+::::::::::::::::::::::ooP'.
+::::::::::::::::::::::...::*/
+
+type (
+	// Результат ежедневной партии
+	DailyResult struct {
+		Date   string  `json:"date"`
+		Player string  `json:"player"`
+		Won    bool    `json:"won"`
+		Time   float64 `json:"time"`
+		BBBV   int32   `json:"bbbv"`
+		Clicks int32   `json:"clicks"`
+	}
+	// Таблица ежедневных результатов, общая для всех игроков на машине
+	Leaderboard struct {
+		path    string
+		Results []DailyResult `json:"results"`
+	}
+)
+
+const (
+	dailyDateFormat = "2006-01-02"
+	// Поиск идет в обработчике события, пока окно ждет. Попытка около 1.3 мс,
+	// за 2024-2025 годы больше 10 попыток не понадобилось, худший случай около 60 мс (BenchmarkDailyWorstCase)
+	dailyAttempts = 50
+)
+
+// Запустить игру сразу с ежедневного поля, флаг -daily
+var startDaily bool
+
+// Зерно из даты, одинаковое у всех в этот день
+func dailySeed(date string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("mines-daily-" + date))
+	return int64(h.Sum64() >> 1)
+}
+
+// Проверка поля решателем, тесты подменяют
+var dailyNoGuess = (*Field).NoGuess
+
+// Ежедневное поле среднего уровня без догадок, стартовая ячейка в центре.
+// Зерна перебираются по порядку, пока решатель не пройдет поле целиком от старта,
+// если ни одно не подошло, берется первое зерно
+func dailyField(date string) (field Field, start int32) {
+	size := presets["intermediate"]
+	start = size.column/2*size.row + size.row/2
+	seed := dailySeed(date)
+	board := func(seed int64) (field Field) {
+		field.New(size)
+		field.SetSeed(seed)
+		field.SetFirstClick("opening")
+		field.Setup(start)
+		return field
+	}
+	for attempt := int64(0); attempt < dailyAttempts; attempt++ {
+		if seed+attempt == 0 {
+			continue
+		}
+		if field = board(seed + attempt); dailyNoGuess(&field, start) {
+			logEngine.Debug("daily", "date", date, "seed", seed+attempt, "attempt", attempt)
+			return field, start
+		}
+	}
+	if seed == 0 {
+		seed = 1 // нулевое зерно значит случайное поле
+	}
+	logEngine.Warn("daily board needs guessing", "date", date, "seed", seed, "attempts", dailyAttempts)
+	return board(seed), start
+}
+
+func (l *Leaderboard) Load(path string) error {
+	l.path = path
+	l.Results = nil
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, l)
+}
+
+func (l *Leaderboard) Save() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(l, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, data, 0644)
+}
+
+func (l *Leaderboard) Record(result DailyResult) {
+	l.Merge([]DailyResult{result})
+	if err := l.Save(); err != nil {
+		logEngine.Error("daily save", "err", err)
+	}
+}
+
+// Добавить чужие результаты, одинаковые записи не повторяются
+func (l *Leaderboard) Merge(results []DailyResult) (added int) {
+	seen := map[DailyResult]bool{}
+	for _, r := range l.Results {
+		seen[r] = true
+	}
+	for _, r := range results {
+		if !seen[r] {
+			seen[r] = true
+			l.Results = append(l.Results, r)
+			added++
+		}
+	}
+	return added
+}
+
+// Победы за день по времени, у каждого игрока лучшая
+func (l *Leaderboard) Ranking(date string) (ranking []DailyResult) {
+	best := map[string]DailyResult{}
+	for _, r := range l.Results {
+		if b, ok := best[r.Player]; r.Date == date && r.Won && (!ok || r.Time < b.Time) {
+			best[r.Player] = r
+		}
+	}
+	for _, r := range best {
+		ranking = append(ranking, r)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Time != ranking[j].Time {
+			return ranking[i].Time < ranking[j].Time
+		}
+		return ranking[i].Player < ranking[j].Player
+	})
+	return ranking
+}
+
+// Место игрока в таблице дня, 0 если побед нет
+func (l *Leaderboard) Place(date, player string) (place, total int) {
+	ranking := l.Ranking(date)
+	for i, r := range ranking {
+		if r.Player == player {
+			place = i + 1
+		}
+	}
+	return place, len(ranking)
+}
+
+/*
+o                        8              8                               8
+8                        8              8                               8
+8     .oPYo. .oPYo. .oPYo8 .oPYo. oPYo. 8oPYo. .oPYo. .oPYo. oPYo. .oPYo8
+8     8oooo8 .oooo8 8    8 8oooo8 8  `' 8    8 8    8 .oooo8 8  `' 8    8
+8     8.     8    8 8    8 8.     8     8    8 8    8 8    8 8     8    8
+8oooo `Yooo' `YooP8 `YooP' `Yooo' 8     `YooP' `YooP' `YooP8 8     `YooP'
+......:.....::.....::.....::.....:..:::::.....::.....::.....:..:::::.....:
+::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::*/
+
+// mines daily export [-o file] | merge file... | show [-date YYYY-MM-DD]
+func dailyCommand(args []string, stdout io.Writer) error {
+	board := &Leaderboard{}
+	if err := board.Load(filepath.Join(dataDir(), "daily.json")); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("usage: mines daily export|merge|show")
+	}
+	fset := flag.NewFlagSet("daily "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "export":
+		out := fset.String("o", "", "output file, stdout if empty")
+		if err := fset.Parse(args[1:]); err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(board, "", "\t")
+		if err != nil {
+			return err
+		}
+		if *out == "" {
+			_, err = stdout.Write(append(data, '\n'))
+			return err
+		}
+		return os.WriteFile(*out, data, 0644)
+	case "merge":
+		if err := fset.Parse(args[1:]); err != nil {
+			return err
+		}
+		for _, name := range fset.Args() {
+			other := &Leaderboard{}
+			if err := other.Load(name); err != nil {
+				return fmt.Errorf("%v: %w", name, err)
+			}
+			fmt.Fprintf(stdout, "%v: %v new results\n", name, board.Merge(other.Results))
+		}
+		return board.Save()
+	case "show":
+		date := fset.String("date", time.Now().Format(dailyDateFormat), "day YYYY-MM-DD")
+		if err := fset.Parse(args[1:]); err != nil {
+			return err
+		}
+		for i, r := range board.Ranking(*date) {
+			fmt.Fprintf(stdout, "%2v. %-16v %8.3f  3BV:%v clicks:%v\n", i+1, r.Player, r.Time, r.BBBV, r.Clicks)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown daily command %q", args[0])
+}
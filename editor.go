@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+ooooo      8  o o
+8          8     8
+o8oo  .oPYo8 o8 o8P .oPYo. oPYo.
+8     8    8  8  8  8    8 8  `'
+8     8    8  8  8  8    8 8
+8oooo `YooP'  8  8  `YooP' 8
+:....::.....::..:..::.....:..::::
+:::::::::::::::::::::::::::::::::
+:::::::::::::::::::::::::::::::::*/
+
+type (
+	// Редактор поля: правит головоломку в том же формате, что читает режим головоломок
+	Editor struct {
+		puzzle *Puzzle
+	}
+)
+
+// Пустое поле заданного размера или копия головоломки для правки
+func (s *Editor) New(size boardConfig, from *Puzzle) {
+	if from != nil {
+		s.puzzle = &Puzzle{Name: from.Name, Row: from.Row, Column: from.Column, Cells: append([]byte(nil), from.Cells...)}
+		return
+	}
+	s.puzzle = &Puzzle{Row: size.row, Column: size.column, Cells: bytes.Repeat([]byte{puzzleSafe}, int(size.row*size.column))}
+}
+
+// Левая кнопка: мина ставится или убирается, цель остается целью
+func (s *Editor) ToggleMine(idx int32) {
+	switch s.puzzle.Cells[idx] {
+	case puzzleMine:
+		s.puzzle.Cells[idx] = puzzleSafe
+	case puzzleTargetMine:
+		s.puzzle.Cells[idx] = puzzleTargetSafe
+	case puzzleTargetSafe:
+		s.puzzle.Cells[idx] = puzzleTargetMine
+	default:
+		s.puzzle.Cells[idx] = puzzleMine
+	}
+}
+
+// Правая кнопка: закрыта -> открыта -> цель -> закрыта, мину открыть нельзя
+func (s *Editor) CycleReveal(idx int32) {
+	switch s.puzzle.Cells[idx] {
+	case puzzleSafe:
+		s.puzzle.Cells[idx] = puzzleOpen
+	case puzzleOpen:
+		s.puzzle.Cells[idx] = puzzleTargetSafe
+	case puzzleTargetSafe:
+		s.puzzle.Cells[idx] = puzzleSafe
+	case puzzleMine:
+		s.puzzle.Cells[idx] = puzzleTargetMine
+	case puzzleTargetMine:
+		s.puzzle.Cells[idx] = puzzleMine
+	}
+}
+
+// Поле для показа: мины видны, открытые ячейки с цифрами пересчитаны по соседям
+func (s *Editor) Values() (board []int32) {
+	field := s.puzzle.Field()
+	for _, cell := range field.field {
+		switch {
+		case cell.GetMines():
+			board = append(board, firstMined)
+		case cell.IsOpened():
+			board = append(board, cell.GetNumber())
+		default:
+			board = append(board, closed)
+		}
+	}
+	return append(board, play)
+}
+
+func (s *Editor) Targets() map[int32]bool {
+	return s.puzzle.Targets()
+}
+
+// 3BV поля и решается ли оно без догадок, без открытых ячеек errNoOpened
+func (s *Editor) Report() (bbbv int32, err error) {
+	field := s.puzzle.Field()
+	bbbv, _ = field.Get3BV()
+	return bbbv, s.puzzle.Validate()
+}
+
+// Сохранить поле отдельным набором, он появится среди головоломок.
+// Файл создается только новым, сохранения в одну секунду получают номер -2, -3...
+func (s *Editor) Save(dir string) (path string, err error) {
+	now := time.Now()
+	if s.puzzle.Name == "" {
+		s.puzzle.Name = now.Format("2006-01-02 15:04:05")
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	var file *os.File
+	suffix, title := "", ""
+	for n := 2; ; n++ {
+		path = filepath.Join(dir, fmt.Sprintf("custom-%v%v.txt", now.Format("20060102-150405"), suffix))
+		if file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+			break
+		}
+		suffix, title = fmt.Sprintf("-%v", n), fmt.Sprintf(" (%v)", n)
+	}
+	if err != nil {
+		return "", err
+	}
+	pack := &PuzzlePack{Name: "Custom " + now.Format("2006-01-02 15:04:05") + title, Puzzles: []*Puzzle{s.puzzle}}
+	err = pack.Format(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
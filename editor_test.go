@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEditorReport(t *testing.T) {
+	var e Editor
+	e.New(boardConfig{row: 4, column: 3}, nil)
+	e.ToggleMine(0)
+	if _, err := e.Report(); !errors.Is(err, errNoOpened) {
+		t.Fatalf("board without opened cells: %v", err)
+	}
+	for idx := int32(1); idx < 12; idx++ {
+		e.CycleReveal(idx)
+	}
+	if _, err := e.Report(); err != nil {
+		t.Errorf("everything but the mine opened: %v", err)
+	}
+}
+
+func TestEditorSaveUnique(t *testing.T) {
+	dir := t.TempDir()
+	var e Editor
+	e.New(boardConfig{row: 4, column: 3}, nil)
+	e.ToggleMine(0)
+	for idx := int32(1); idx < 12; idx++ {
+		e.CycleReveal(idx)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		path, err := e.Save(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[path] {
+			t.Fatalf("save %v overwrites %v", i, path)
+		}
+		seen[path] = true
+	}
+	names, _ := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if len(names) != 3 {
+		t.Fatalf("files %v", names)
+	}
+	packs := LoadPuzzlePacks(os.DirFS(dir))
+	keys := map[string]bool{}
+	for _, pack := range packs {
+		keys[puzzleKey(pack, pack.Puzzles[0])] = true
+	}
+	if len(packs) != 3 || len(keys) != 3 {
+		t.Errorf("%v packs with %v distinct keys", len(packs), len(keys))
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+o                                 o
+8                                  8
+8     .oPYo. o    o .oPYo. o    o o8P
+8     .oooo8 8    8 8    8 8    8  8
+8     8    8 8    8 8    8 8    8  8
+8oooo `YooP8 `YooP8 `YooP' `YooP'  8
+......:.....::....8 :.....::.....::..:
+:::::::::::::::ooP'.::::::::::::::::::
+:::::::::::::::...::::::::::::::::::::*/
+
+type (
+	// Расстановка мин без состояния игры, для обмена с другими программами
+	Layout struct {
+		size  boardConfig
+		mines []bool
+		// Вопросы правой кнопкой из заголовка Marks повтора, marksKnown false, если заголовка нет
+		marks, marksKnown bool
+	}
+)
+
+// Размеры уровней в заголовке AVF
+var avfModes = map[byte]boardConfig{
+	3: {row: 8, column: 8, mines: 10},
+	4: {row: 16, column: 16, mines: 40},
+	5: {row: 30, column: 16, mines: 99},
+}
+
+const avfCustom = 6
+
+// Начать игру на расстановке из файла, флаг -board
+var boardFile string
+
+// Размер, который игра может нарисовать, и хотя бы одна ячейка без мины
+func checkSize(size boardConfig) error {
+	if size.row < minRow || size.row > maxRow || size.column < minColumn || size.column > maxColumn {
+		return fmt.Errorf("board %vx%v, want %vx%v to %vx%v", size.row, size.column, minRow, minColumn, maxRow, maxColumn)
+	}
+	if size.mines >= size.row*size.column {
+		return fmt.Errorf("board %vx%v with %v mines has no free cell", size.row, size.column, size.mines)
+	}
+	return nil
+}
+
+func NewLayout(size boardConfig) Layout {
+	return Layout{size: size, mines: make([]bool, size.row*size.column)}
+}
+
+func (l Layout) Mines() (mines int32) {
+	for _, mine := range l.mines {
+		if mine {
+			mines++
+		}
+	}
+	return mines
+}
+
+// Головоломка без открытых ячеек и целей: играется как обычное поле с готовыми минами
+func (l Layout) Puzzle(name string) *Puzzle {
+	p := &Puzzle{Name: name, Row: l.size.row, Column: l.size.column}
+	for _, mine := range l.mines {
+		if mine {
+			p.Cells = append(p.Cells, puzzleMine)
+		} else {
+			p.Cells = append(p.Cells, puzzleSafe)
+		}
+	}
+	return p
+}
+
+// Расставить мины по образцу, пересчитать цифры, игра сразу идет
+func (s *Field) SetLayout(l Layout) {
+	s.New(boardConfig{row: l.size.row, column: l.size.column, mines: l.Mines()})
+	for idx, mine := range l.mines {
+		if mine {
+			s.field[idx].SetMines()
+		}
+	}
+	s.setNumbers()
+	s.SetState(gamePlay)
+}
+
+func (s *Field) GetLayout() Layout {
+	l := NewLayout(s.boardSize)
+	for idx, cell := range s.field {
+		l.mines[idx] = cell.GetMines()
+	}
+	return l
+}
+
+// Карта мин: строка на ряд, * мина, . пусто. Пустые строки и # комментарии пропускаются
+func ParseMineMap(r io.Reader) (l Layout, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.Trim(text, "*.") != "" {
+			return l, fmt.Errorf("line %v: want only * and . in %q", line, text)
+		}
+		if l.size.row == 0 {
+			l.size.row = int32(len(text))
+		} else if l.size.row != int32(len(text)) {
+			return l, fmt.Errorf("line %v: row length %v, want %v", line, len(text), l.size.row)
+		}
+		for _, c := range text {
+			l.mines = append(l.mines, c == '*')
+		}
+		l.size.column++
+	}
+	if err = scanner.Err(); err != nil {
+		return l, err
+	}
+	if l.size.column == 0 {
+		return l, errors.New("empty mine map")
+	}
+	l.size.mines = l.Mines()
+	return l, nil
+}
+
+func FormatMineMap(w io.Writer, l Layout) error {
+	for y := int32(0); y < l.size.column; y++ {
+		row := make([]byte, l.size.row)
+		for x := range row {
+			row[x] = '.'
+			if l.mines[y*l.size.row+int32(x)] {
+				row[x] = '*'
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Текстовый повтор Viennasweeper: из заголовка Width, Height, Mines, Marks и раздел Board, где * мина, 0 пусто.
+// Остальные поля и события повтора пропускаются
+func ParseRawVF(r io.Reader) (l Layout, err error) {
+	scanner := bufio.NewScanner(r)
+	inBoard := false
+	var mines int64
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if inBoard {
+			if text == "" || strings.Contains(text, ":") {
+				break
+			}
+			if int32(len(text)) != l.size.row {
+				return l, fmt.Errorf("rawvf board row length %v, want %v", len(text), l.size.row)
+			}
+			for _, c := range text {
+				l.mines = append(l.mines, c == '*')
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(text, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Width":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return l, fmt.Errorf("rawvf width: %w", err)
+			}
+			l.size.row = int32(n)
+		case "Height":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return l, fmt.Errorf("rawvf height: %w", err)
+			}
+			l.size.column = int32(n)
+		case "Mines":
+			if mines, err = strconv.ParseInt(value, 10, 32); err != nil {
+				return l, fmt.Errorf("rawvf mines: %w", err)
+			}
+		case "Marks":
+			switch strings.ToLower(value) {
+			case "on":
+				l.marks = true
+			case "off":
+				l.marks = false
+			default:
+				return l, fmt.Errorf("rawvf marks %q, want On or Off", value)
+			}
+			l.marksKnown = true
+		case "Board":
+			if l.size.row <= 0 || l.size.column <= 0 {
+				return l, errors.New("rawvf board before its size")
+			}
+			if err = checkSize(l.size); err != nil {
+				return l, fmt.Errorf("rawvf: %w", err)
+			}
+			inBoard = true
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return l, err
+	}
+	if int32(len(l.mines)) != l.size.row*l.size.column {
+		return l, fmt.Errorf("rawvf board has %v cells, want %v", len(l.mines), l.size.row*l.size.column)
+	}
+	if l.size.mines = l.Mines(); mines != 0 && int64(l.size.mines) != mines {
+		return l, fmt.Errorf("rawvf board has %v mines, header says %v", l.size.mines, mines)
+	}
+	return l, nil
+}
+
+// Заголовок и раздел Board текстового повтора, без событий; Marks только если он известен
+func FormatRawVF(w io.Writer, l Layout) error {
+	level := "Custom"
+	switch presetName(l.size) {
+	case "beginner":
+		level = "Beginner"
+	case "intermediate":
+		level = "Intermediate"
+	case "expert":
+		level = "Expert"
+	}
+	marks := ""
+	if l.marksKnown && l.marks {
+		marks = "Marks: On\n"
+	} else if l.marksKnown {
+		marks = "Marks: Off\n"
+	}
+	if _, err := fmt.Fprintf(w, "RawVF_Version: Rev6\nProgram: Mines\nLevel: %v\nWidth: %v\nHeight: %v\nMines: %v\n%vBoard:\n", level, l.size.row, l.size.column, l.Mines(), marks); err != nil {
+		return err
+	}
+	for y := int32(0); y < l.size.column; y++ {
+		row := make([]byte, l.size.row)
+		for x := range row {
+			row[x] = '0'
+			if l.mines[y*l.size.row+int32(x)] {
+				row[x] = '*'
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Раздел расстановки двоичного повтора Minesweeper Arbiter: версия, 4 байта, уровень,
+// для особого уровня ширина-1, высота-1 и число мин, затем каждая мина парой ряд, столбец с единицы
+func ParseAVF(r io.Reader) (l Layout, err error) {
+	header := make([]byte, 6)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return l, fmt.Errorf("avf header: %w", err)
+	}
+	size, ok := avfModes[header[5]]
+	if header[5] == avfCustom {
+		custom := make([]byte, 4)
+		if _, err = io.ReadFull(r, custom); err != nil {
+			return l, fmt.Errorf("avf size: %w", err)
+		}
+		size = boardConfig{row: int32(custom[0]) + 1, column: int32(custom[1]) + 1, mines: int32(binary.BigEndian.Uint16(custom[2:]))}
+	} else if !ok {
+		return l, fmt.Errorf("avf unknown mode %v", header[5])
+	}
+	if err = checkSize(size); err != nil {
+		return l, fmt.Errorf("avf: %w", err)
+	}
+	l = NewLayout(size)
+	pos := make([]byte, 2)
+	for i := int32(0); i < size.mines; i++ {
+		if _, err = io.ReadFull(r, pos); err != nil {
+			return l, fmt.Errorf("avf mine %v: %w", i, err)
+		}
+		y, x := int32(pos[0])-1, int32(pos[1])-1
+		if x < 0 || y < 0 || x >= size.row || y >= size.column {
+			return l, fmt.Errorf("avf mine %v outside the board", i)
+		}
+		l.mines[y*size.row+x] = true
+	}
+	return l, nil
+}
+
+// Формат по расширению: .avf, .rawvf, иначе карта мин; повтор RawVF узнается и по первой строке.
+// Поле, которое игра не нарисует, не принимается в любом формате
+func ImportLayout(path string) (l Layout, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return l, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".avf":
+		l, err = ParseAVF(bytes.NewReader(data))
+	case ext == ".rawvf" || bytes.HasPrefix(data, []byte("RawVF_Version")):
+		l, err = ParseRawVF(bytes.NewReader(data))
+	default:
+		l, err = ParseMineMap(bytes.NewReader(data))
+	}
+	if err != nil {
+		return l, err
+	}
+	return l, checkSize(l.size)
+}
+
+// Формат по расширению: .rawvf или карта мин
+func ExportLayout(path string, l Layout) error {
+	buf := &bytes.Buffer{}
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".rawvf" {
+		err = FormatRawVF(buf, l)
+	} else {
+		err = FormatMineMap(buf, l)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// mines convert in out: перевод расстановки между форматами
+func convertCommand(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: mines convert in.{avf,rawvf,txt} out.{rawvf,txt}")
+	}
+	l, err := ImportLayout(args[0])
+	if err != nil {
+		return fmt.Errorf("%v: %w", args[0], err)
+	}
+	return ExportLayout(args[1], l)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Поле 5x5 с минами в углах
+var cornerMap = []string{
+	"*...*",
+	".....",
+	".....",
+	".....",
+	"*...*",
+}
+
+// Повтор RawVF с заголовком и разделом Board
+func rawVF(header string, rows ...string) string {
+	return "RawVF_Version: Rev6\n" + header + "Board:\n" + strings.Join(rows, "\n") + "\n"
+}
+
+// Двоичный AVF: заголовок, для особого уровня размер, затем мины парами ряд, столбец с единицы
+func avf(mode byte, custom []byte, mines ...[2]byte) []byte {
+	data := []byte{1, 0, 0, 0, 0, mode}
+	data = append(data, custom...)
+	for _, m := range mines {
+		data = append(data, m[0], m[1])
+	}
+	return data
+}
+
+func avfSize(row, column byte, mines uint16) []byte {
+	custom := []byte{row - 1, column - 1, 0, 0}
+	binary.BigEndian.PutUint16(custom[2:], mines)
+	return custom
+}
+
+func sameMines(a, b Layout) bool {
+	if a.size.row != b.size.row || a.size.column != b.size.column || len(a.mines) != len(b.mines) {
+		return false
+	}
+	for idx := range a.mines {
+		if a.mines[idx] != b.mines[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseMineMap(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		size  boardConfig
+		mines []int32
+		fail  bool
+	}{
+		{"corners", strings.Join(cornerMap, "\n"), boardConfig{row: 5, column: 5, mines: 4}, []int32{0, 4, 20, 24}, false},
+		{"comments and blank lines", "# board\n\n*.\n.*\n", boardConfig{row: 2, column: 2, mines: 2}, []int32{0, 3}, false},
+		{"bad character", "*.\n.x\n", boardConfig{}, nil, true},
+		{"ragged row", "*..\n.*\n", boardConfig{}, nil, true},
+		{"empty", "# nothing\n", boardConfig{}, nil, true},
+	}
+	for _, tt := range tests {
+		l, err := ParseMineMap(strings.NewReader(tt.text))
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%v: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if l.size != tt.size {
+			t.Errorf("%v: size %+v, want %+v", tt.name, l.size, tt.size)
+		}
+		for _, idx := range tt.mines {
+			if !l.mines[idx] {
+				t.Errorf("%v: no mine at %v", tt.name, idx)
+			}
+		}
+	}
+}
+
+func TestParseRawVF(t *testing.T) {
+	size := "Width: 5\nHeight: 5\n"
+	board := []string{"*000*", "00000", "00000", "00000", "*000*"}
+	want := mineMap(t, cornerMap...).GetLayout()
+	tests := []struct {
+		name string
+		text string
+		fail bool
+	}{
+		{"board", rawVF(size+"Mines: 4\n", board...), false},
+		{"events after the board", rawVF(size, board...) + "Events:\n0.00 lc 1 1\n", false},
+		{"no mines header", rawVF(size, board...), false},
+		{"mines differ", rawVF(size+"Mines: 5\n", board...), true},
+		{"short row", rawVF(size, append([]string{"*00*"}, board[1:]...)...), true},
+		{"missing row", rawVF(size, board[1:]...), true},
+		{"board before size", rawVF("", board...), true},
+		{"bad width", rawVF("Width: five\nHeight: 5\n", board...), true},
+		{"too small", rawVF("Width: 2\nHeight: 2\n", "*0", "0*"), true},
+		{"too wide", rawVF("Width: 31\nHeight: 5\n", board...), true},
+	}
+	for _, tt := range tests {
+		l, err := ParseRawVF(strings.NewReader(tt.text))
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%v: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if !sameMines(l, want) {
+			t.Errorf("%v: mines differ", tt.name)
+		}
+	}
+}
+
+func TestParseAVF(t *testing.T) {
+	beginner := [][2]byte{{1, 1}, {1, 8}, {8, 1}, {8, 8}, {2, 2}, {3, 3}, {4, 4}, {5, 5}, {6, 6}, {7, 7}}
+	tests := []struct {
+		name  string
+		data  []byte
+		size  boardConfig
+		mines []int32
+		fail  bool
+	}{
+		{"beginner", avf(3, nil, beginner...), avfModes[3], []int32{0, 7, 56, 63, 9}, false},
+		{"custom", avf(avfCustom, avfSize(6, 5, 2), [2]byte{1, 1}, [2]byte{5, 6}), boardConfig{row: 6, column: 5, mines: 2}, []int32{0, 29}, false},
+		{"unknown mode", avf(9, nil), boardConfig{}, nil, true},
+		{"short header", []byte{1, 0, 0}, boardConfig{}, nil, true},
+		{"missing mine", avf(avfCustom, avfSize(6, 5, 3), [2]byte{1, 1}), boardConfig{}, nil, true},
+		{"mine outside", avf(avfCustom, avfSize(6, 5, 1), [2]byte{6, 1}), boardConfig{}, nil, true},
+		{"too big", avf(avfCustom, avfSize(255, 255, 1), [2]byte{1, 1}), boardConfig{}, nil, true},
+		{"too small", avf(avfCustom, avfSize(2, 2, 1), [2]byte{1, 1}), boardConfig{}, nil, true},
+		{"no free cell", avf(avfCustom, avfSize(5, 5, 25)), boardConfig{}, nil, true},
+	}
+	for _, tt := range tests {
+		l, err := ParseAVF(bytes.NewReader(tt.data))
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%v: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if l.size.row != tt.size.row || l.size.column != tt.size.column || l.Mines() != tt.size.mines {
+			t.Errorf("%v: size %+v with %v mines, want %+v", tt.name, l.size, l.Mines(), tt.size)
+		}
+		for _, idx := range tt.mines {
+			if !l.mines[idx] {
+				t.Errorf("%v: no mine at %v", tt.name, idx)
+			}
+		}
+	}
+}
+
+func TestLayoutRoundTrip(t *testing.T) {
+	for _, rows := range [][]string{cornerMap, {"*....", ".*...", "..*..", "...*.", "....*", "*****"}} {
+		want := mineMap(t, rows...).GetLayout()
+		buf := &bytes.Buffer{}
+		if err := FormatMineMap(buf, want); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ParseMineMap(buf); err != nil || !sameMines(got, want) {
+			t.Errorf("mine map round trip %v: %v", rows, err)
+		}
+		buf.Reset()
+		if err := FormatRawVF(buf, want); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ParseRawVF(buf); err != nil || !sameMines(got, want) {
+			t.Errorf("rawvf round trip %v: %v", rows, err)
+		}
+	}
+}
+
+func TestImportLayoutSize(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	if _, err := ImportLayout(write("ok.txt", strings.Join(cornerMap, "\n"))); err != nil {
+		t.Errorf("5x5 map: %v", err)
+	}
+	if _, err := ImportLayout(write("small.txt", "*..\n...\n")); err == nil {
+		t.Error("3x2 map accepted")
+	}
+	if _, err := ImportLayout(write("full.txt", strings.Repeat("*****\n", 5))); err == nil {
+		t.Error("map without a free cell accepted")
+	}
+	if err := convertCommand([]string{write("wide.txt", strings.Repeat(strings.Repeat(".", 31)+"\n", 5)), filepath.Join(dir, "out.rawvf")}); err == nil {
+		t.Error("31 wide map converted")
+	}
+}
+
+func TestRawVFMarks(t *testing.T) {
+	for _, tt := range []struct {
+		marks, known bool
+		header       string
+	}{
+		{true, true, "Marks: On\n"},
+		{false, true, "Marks: Off\n"},
+		{false, false, ""},
+	} {
+		l := mineMap(t, cornerMap...).GetLayout()
+		l.marks, l.marksKnown = tt.marks, tt.known
+		buf := &bytes.Buffer{}
+		if err := FormatRawVF(buf, l); err != nil {
+			t.Fatal(err)
+		}
+		if tt.header != "" && !strings.Contains(buf.String(), tt.header) || tt.header == "" && strings.Contains(buf.String(), "Marks") {
+			t.Errorf("header %q in\n%v", tt.header, buf)
+		}
+		got, err := ParseRawVF(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.marks != tt.marks || got.marksKnown != tt.known {
+			t.Errorf("marks %v known %v, want %v %v", got.marks, got.marksKnown, tt.marks, tt.known)
+		}
+	}
+	if _, err := ParseRawVF(strings.NewReader(rawVF("Width: 5\nHeight: 5\nMarks: maybe\n", "00000"))); err == nil {
+		t.Error("bad Marks value accepted")
+	}
+}
+
+func TestExportKeepsQuestionMarks(t *testing.T) {
+	c, _, _ := newTestController(t)
+	config.QuestionMarks = false
+	c.mines.field = *mineMap(t, cornerMap...)
+	dir := t.TempDir()
+	paths, err := c.Export(dir)
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("export %v: %v", paths, err)
+	}
+	l, err := ImportLayout(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.marksKnown || l.marks {
+		t.Errorf("replay marks %v known %v, want off", l.marks, l.marksKnown)
+	}
+}
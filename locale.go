@@ -0,0 +1,216 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+/*
+o                          8
+8                          8
+8     .oPYo. .oPYo. .oPYo. 8 .oPYo.
+8     8    8 8    ' .oooo8 8 8oooo8
+8     8    8 8    . 8    8 8 8.
+8oooo `YooP' `YooP' `YooP8 8 `Yooo'
+......:.....::.....::.....:..:.....:
+::::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::::*/
+
+// Каталог строк интерфейса по языкам
+var (
+	messages = map[string]map[string]string{
+		"en": {
+			"pause":       "Pause",
+			"reset":       "Reset",
+			"new":         "New",
+			"rows":        "Rows",
+			"columns":     "Columns",
+			"mines":       "Mines",
+			"message":     "Message",
+			"ok":          "Ok",
+			"you_win":     "You Win",
+			"game_over":   "Game Over",
+			"flags_mines": "F:%v/M:%v",
+
+			"flags_summary": "Flags: %v right, %v wrong",
+			"time_bbbv":     "Time: %.3f  3BV: %v/%v  3BV/s: %.2f",
+			"clicks":        "Clicks: %v (L %v, R %v, C %v, wasted %v)",
+			"efficiency":    "Efficiency: %.0f%%",
+			"daily_place":   "Daily %v: place %v of %v",
+			"puzzles":       "Puzzles",
+			"puzzle_pack":   "Pack",
+
+			"editor_3bv":        "3BV:%v",
+			"editor_solvable":   "no guess",
+			"editor_guess":      "guess",
+			"editor_no_opened":  "no opened cells",
+			"editor_saved":      "saved",
+			"editor_save_error": "not saved",
+
+			"statistics":       "Statistics",
+			"preset":           "Level",
+			"beginner":         "beginner",
+			"intermediate":     "intermediate",
+			"expert":           "expert",
+			"custom":           "custom",
+			"stats_player":     "Player: %v",
+			"stats_games":      "Games: %v, won: %v (%v%%)",
+			"stats_streaks":    "Streak: %v, best: %v",
+			"stats_preset":     "Level games: %v, won: %v",
+			"stats_times":      "Best time: %.3f, average: %.3f",
+			"stats_bbbvs":      "3BV/s best: %.2f, average: %.2f",
+			"stats_efficiency": "Efficiency: %.0f%%",
+			"forced":           "Forced guess, nothing was provable",
+			"avoidable_safe":   "Avoidable, a safe cell was provable",
+			"avoidable_mine":   "Avoidable, this mine was provable",
+
+			"settings":       "Settings",
+			"question_marks": "Marks ?",
+			"first_click":    "First click",
+			"chord":          "Chord",
+			"theme":          "Theme",
+			"glyphs":         "Glyphs",
+			"language":       "Language",
+			"sound":          "Sound",
+			"volume":         "Volume",
+			"animation":      "Animation",
+			"on":             "on",
+			"off":            "off",
+			"cell":           "cell",
+			"opening":        "opening",
+			"left":           "left click",
+			"both":           "both buttons",
+			"auto_flag":      "Auto flags",
+			"auto":           "auto",
+			"slow":           "slow",
+			"normal":         "normal",
+			"fast":           "fast",
+			"classic":        "classic",
+			"deuteranopia":   "deuteranopia",
+			"protanopia":     "protanopia",
+			"tritanopia":     "tritanopia",
+			"en":             "English",
+			"ru":             "Русский",
+		},
+		"ru": {
+			"pause":       "Пауза",
+			"reset":       "Сброс",
+			"new":         "Новая",
+			"rows":        "Строк",
+			"columns":     "Рядов",
+			"mines":       "Мин",
+			"message":     "Сообщение",
+			"ok":          "Ок",
+			"you_win":     "Победа",
+			"game_over":   "Игра окончена",
+			"flags_mines": "Ф:%v/М:%v",
+
+			"flags_summary": "Флаги: %v верно, %v ошибочно",
+			"time_bbbv":     "Время: %.3f  3BV: %v/%v  3BV/s: %.2f",
+			"clicks":        "Нажатий: %v (Л %v, П %v, А %v, лишних %v)",
+			"efficiency":    "Эффективность: %.0f%%",
+			"daily_place":   "Задача дня %v: место %v из %v",
+			"puzzles":       "Головоломки",
+			"puzzle_pack":   "Набор",
+
+			"editor_3bv":        "3BV:%v",
+			"editor_solvable":   "без догадок",
+			"editor_guess":      "с догадкой",
+			"editor_no_opened":  "нет открытых",
+			"editor_saved":      "сохранено",
+			"editor_save_error": "не сохранено",
+
+			"statistics":       "Статистика",
+			"preset":           "Уровень",
+			"beginner":         "новичок",
+			"intermediate":     "любитель",
+			"expert":           "профессионал",
+			"custom":           "особый",
+			"stats_player":     "Игрок: %v",
+			"stats_games":      "Игр: %v, побед: %v (%v%%)",
+			"stats_streaks":    "Серия: %v, лучшая: %v",
+			"stats_preset":     "Игр уровня: %v, побед: %v",
+			"stats_times":      "Лучшее время: %.3f, среднее: %.3f",
+			"stats_bbbvs":      "3BV/s лучшее: %.2f, среднее: %.2f",
+			"stats_efficiency": "Эффективность: %.0f%%",
+			"forced":           "Вынужденная догадка",
+			"avoidable_safe":   "Ошибка, была верная пустая ячейка",
+			"avoidable_mine":   "Ошибка, эта мина выводилась",
+
+			"settings":       "Настройки",
+			"question_marks": "Знак ?",
+			"first_click":    "Первый ход",
+			"chord":          "Аккорд",
+			"theme":          "Палитра",
+			"glyphs":         "Фигуры",
+			"language":       "Язык",
+			"sound":          "Звук",
+			"volume":         "Громкость",
+			"animation":      "Анимация",
+			"on":             "вкл",
+			"off":            "выкл",
+			"cell":           "ячейка",
+			"opening":        "область",
+			"left":           "левая кнопка",
+			"both":           "обе кнопки",
+			"auto_flag":      "Автофлаги",
+			"auto":           "авто",
+			"slow":           "медленно",
+			"normal":         "обычно",
+			"fast":           "быстро",
+			"classic":        "классика",
+			"deuteranopia":   "дейтеранопия",
+			"protanopia":     "протанопия",
+			"tritanopia":     "тританопия",
+			"en":             "English",
+			"ru":             "Русский",
+		},
+	}
+	locale = detectLocale()
+)
+
+// Строка на текущем языке, при отсутствии перевода английская, затем сам ключ
+func tr(key string) string {
+	if text, ok := messages[locale][key]; ok {
+		return text
+	}
+	if text, ok := messages["en"][key]; ok {
+		return text
+	}
+	return key
+}
+
+// Язык из переменных окружения LC_ALL, LC_MESSAGES, LANG, по умолчанию английский
+func detectLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return parseLocale(value)
+		}
+	}
+	return "en"
+}
+
+// ru_RU.UTF-8 -> ru, неизвестный язык -> en
+func parseLocale(value string) string {
+	lang := strings.ToLower(value)
+	if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+		lang = lang[:i]
+	}
+	if _, ok := messages[lang]; ok {
+		return lang
+	}
+	return "en"
+}
+
+func setLocale(value string) {
+	locale = parseLocale(value)
+}
+
+// Язык из настроек, пустой значит из окружения
+func applyLocale() {
+	if config.Language == "" {
+		locale = detectLocale()
+	} else {
+		setLocale(config.Language)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+/*
+o                           o
+8
+8     .oPYo. .oPYo. .oPYo. o8 odYo. .oPYo.
+8     8    8 8    8 8    8  8 8' `8 8    8
+8     8    8 8    8 8    8  8 8   8 8    8
+8oooo `YooP' `YooP8 `YooP8  8 8   8 `YooP8
+......:.....::....8 :....8 :....::..:....8
+:::::::::::::::ooP'.::ooP'.:::::::::::ooP'.
+:::::::::::::::...::::...:::::::::::::...::*/
+
+// Журнал по подсистемам: engine поле и партия, ui отрисовка и настройки,
+// input мышь и клавиатура.
+// По умолчанию журнал выключен, включается флагом -log или переменной MINES_LOG:
+// engine,input=info,json, all или all=warn. Уровень off выключает подсистему
+var (
+	logEngine *slog.Logger
+	logUI     *slog.Logger
+	logInput  *slog.Logger
+)
+
+var logCategories = []string{"engine", "ui", "input"}
+
+// Уровень выше любой записи: подсистема молчит
+const logOff = slog.LevelError + 4
+
+func init() {
+	// ошибку в переменной окружения покажет parseFlags, здесь остаются уровни по умолчанию
+	if setupLogging(os.Getenv("MINES_LOG"), os.Stderr) != nil {
+		setupLogging("", os.Stderr)
+	}
+}
+
+// Уровни подсистем из строки вида engine=debug,ui,json. Подсистема без уровня пишет все, не названная молчит
+func parseLogSpec(spec string) (levels map[string]slog.Level, json bool, err error) {
+	levels = map[string]slog.Level{}
+	for _, name := range logCategories {
+		levels[name] = logOff
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if item == "json" {
+			json = true
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		level := slog.LevelDebug
+		if ok && strings.EqualFold(value, "off") {
+			level = logOff
+		} else if ok {
+			if err = level.UnmarshalText([]byte(value)); err != nil {
+				return nil, false, fmt.Errorf("log %v: %w", item, err)
+			}
+		}
+		if name == "all" {
+			for _, name := range logCategories {
+				levels[name] = level
+			}
+		} else if _, ok := levels[name]; ok {
+			levels[name] = level
+		} else {
+			return nil, false, fmt.Errorf("log %v: unknown category, use %v or all", item, strings.Join(logCategories, ", "))
+		}
+	}
+	return levels, json, nil
+}
+
+func setupLogging(spec string, w io.Writer) error {
+	levels, json, err := parseLogSpec(spec)
+	if err != nil {
+		return err
+	}
+	logger := func(name string) *slog.Logger {
+		opts := &slog.HandlerOptions{Level: levels[name]}
+		if json {
+			return slog.New(slog.NewJSONHandler(w, opts)).With("cat", name)
+		}
+		return slog.New(slog.NewTextHandler(w, opts)).With("cat", name)
+	}
+	logEngine, logUI, logInput = logger("engine"), logger("ui"), logger("input")
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLogSpec(t *testing.T) {
+	tests := []struct {
+		spec   string
+		levels map[string]slog.Level
+		json   bool
+	}{
+		{"", map[string]slog.Level{"engine": logOff, "input": logOff}, false},
+		{"engine", map[string]slog.Level{"engine": slog.LevelDebug, "ui": logOff}, false},
+		{"all=info, input ,json", map[string]slog.Level{"engine": slog.LevelInfo, "ui": slog.LevelInfo, "input": slog.LevelDebug}, true},
+		{"ui=error", map[string]slog.Level{"ui": slog.LevelError, "engine": logOff}, false},
+		{"all=warn,input=off", map[string]slog.Level{"ui": slog.LevelWarn, "input": logOff}, false},
+	}
+	for _, tt := range tests {
+		levels, json, err := parseLogSpec(tt.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.spec, err)
+		}
+		if json != tt.json {
+			t.Errorf("%q: json %v", tt.spec, json)
+		}
+		for name, want := range tt.levels {
+			if levels[name] != want {
+				t.Errorf("%q: %v level %v, want %v", tt.spec, name, levels[name], want)
+			}
+		}
+	}
+	for _, spec := range []string{"sound", "net", "engine=loud", "=debug"} {
+		if _, _, err := parseLogSpec(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
+
+func TestLoggingOutput(t *testing.T) {
+	defer setupLogging("", os.Stderr)
+	var buf bytes.Buffer
+	if err := setupLogging("", &buf); err != nil {
+		t.Fatal(err)
+	}
+	logEngine.Error("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("logging is on by default: %q", buf.String())
+	}
+	if err := setupLogging("engine=info,ui=error,json", &buf); err != nil {
+		t.Fatal(err)
+	}
+	logEngine.Debug("hidden")
+	logInput.Error("hidden")
+	logEngine.Info("shown", "cell", 5)
+	logUI.Error("failed")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("log lines %q", lines)
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["cat"] != "engine" || record["msg"] != "shown" || record["cell"] != 5.0 {
+		t.Errorf("record %v", record)
+	}
+	if !strings.Contains(lines[1], `"cat":"ui"`) {
+		t.Errorf("error line %q", lines[1])
+	}
+}
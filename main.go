@@ -2,9 +2,9 @@ package main
 
 import (
 	"fmt"
-	"log"
-	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -17,14 +17,14 @@ type (
 	// Интерфейс наблюдателей
 	Observers interface {
 		Setup()
-		Update(Event)
+		Update(Message)
 		Render(*sdl.Renderer)
-		Event(sdl.Event) Event
+		Event(sdl.Event) Message
 	}
 	// Модель
 	Mines struct {
-		subsribers []Observers
-		field      Field
+		bus   Bus
+		field Field
 	}
 	// Ячейка минного поля
 	Cell struct {
@@ -36,10 +36,21 @@ type (
 	// Минное поле
 	minesStateType int32
 	Field          struct {
-		field     []Cell
-		state     minesStateType
-		boardSize boardConfig
-	}
+		field         []Cell
+		state         minesStateType
+		boardSize     boardConfig
+		seed          int64
+		firstClick    string
+		questionMarks bool
+		lastView      []int32
+		bbbv          int32
+		clicks        [3]int32
+		wastedClicks  int32
+		waves         map[int32]int32
+		targets       map[int32]bool
+	}
+	// Вид нажатия для подсчета: левая, правая, аккорд
+	clickType int32
 	// Волчок Контроллер
 	Spinner struct{ mines Mines }
 	// Вид Представление
@@ -49,6 +60,8 @@ type (
 		event                  sdl.Event
 		pushTime, lastPushTime uint32
 		flags                  uint32
+		screenshot             string
+		paused                 bool
 	}
 	// Наблюдатель строка меню
 	StatusLine struct {
@@ -64,9 +77,16 @@ type (
 		colors                []sdl.Color
 		gameBoardSize         boardConfig
 		cellWidth, cellHeight int32
-		mousePressedAtButton  int32
 		messageBox            *MessageBox
 		start                 bool
+		board                 []int32
+		stat                  []int
+		leftDown, rightDown   bool
+		chording, chordDone   bool
+		preview               int32
+		summary               []string
+		paused                bool
+		targets               map[int32]bool
 	}
 	// Кнопки строки статуса
 	buttonsType int
@@ -78,6 +98,71 @@ type (
 	}
 	// События
 	Event int
+	// Сообщение шины: вид события и данные к нему, событие без данных само себе сообщение
+	Message interface {
+		Kind() Event
+	}
+	// Кнопка мыши отпущена на ячейке
+	CellEvent struct {
+		Event
+		Idx int32
+	}
+	// Размер поля из строки статуса
+	BoardEvent struct {
+		Event
+		Size boardConfig
+	}
+	// Итог партии: точное время для часов и строки итогов для окна сообщения
+	OutcomeEvent struct {
+		Event
+		State   minesStateType
+		Seconds float64
+		BBBV    int32
+		Time    string
+		Summary []string
+	}
+	// Поле для показа, Fresh значит новое поле вместо прежнего
+	FieldEvent struct {
+		Event
+		Values  []int32
+		Stat    []int
+		Size    boardConfig
+		Targets map[int32]bool
+		Fresh   bool
+	}
+	// Открытые ячейки по волнам обхода
+	OpenEvent struct {
+		Event
+		Waves map[int32]int32
+	}
+	// Роковая мина и все мины поля
+	BlastEvent struct {
+		Event
+		Origin int32
+		Mines  []int32
+	}
+	// Секунды, минуты, часы, дни на часах
+	TimerEvent struct {
+		Event
+		Timer []uint32
+	}
+	// Надписи под полем
+	InfoEvent struct {
+		Event
+		Left, Right string
+	}
+	// Выбранная головоломка и ее ключ для отметки о решении
+	PuzzleEvent struct {
+		Event
+		Puzzle *Puzzle
+		Key    string
+	}
+	// Шина сообщений: слои рисуются и получают ввод, подписчики получают сообщения своего вида
+	Bus struct {
+		layers   []Observers
+		handlers map[Event][]func(Message)
+		queue    []Message
+	}
 	// Размеры минного поля
 	boardConfig struct {
 		row, column, mines, minesPercent int32
@@ -121,14 +206,17 @@ type (
 		titleLabel   Label
 		message      string
 		messageLabel Label
+		details      []*Label
 		okButton     Button
 		Hide         bool
 		fg, bg       sdl.Color
 	}
-	// Умеет засекать время. Умеет работать с паузой
+	// Секундомер по монотонным часам, clock можно подменить
 	Timer struct {
-		nowTick, startTick, mSec, seconds uint32
-		running, pause                    bool
+		clock            func() time.Time
+		startTime        time.Time
+		elapsed          time.Duration
+		started, running bool
 	}
 )
 
@@ -154,6 +242,33 @@ const (
 	MouseButtonLeftReleasedEvent
 	MouseButtonRightPressedEvent
 	MouseButtonRightReleasedEvent
+	NextPaletteEvent
+	GlyphsToggleEvent
+	SettingsToggleEvent
+	SettingsChangedEvent
+	ConsumedEvent
+	ChordEvent
+	StatsToggleEvent
+	GameEndEvent
+	FocusLostEvent
+	AnyKeyEvent
+	CellOpenedEvent
+	CascadeEvent
+	FlagChangedEvent
+	ChordOpenedEvent
+	ExplosionEvent
+	VictoryEvent
+	DailyEvent
+	PuzzleToggleEvent
+	PuzzleSelectedEvent
+	EditorToggleEvent
+	EditorSaveEvent
+	ExportEvent
+	ScreenshotEvent
+	FieldChangedEvent
+	TimerChangedEvent
+	InfoChangedEvent
+	PuzzlesChangedEvent
 )
 
 // перечень кнопок строки статуса
@@ -199,6 +314,13 @@ const (
 	gameOver
 )
 
+// виды нажатий для подсчета эффективности
+const (
+	leftClick clickType = iota
+	rightClick
+	chordClick
+)
+
 // константы размеров минного поля
 const (
 	minRow    = 5
@@ -212,13 +334,13 @@ const (
 var (
 	mn                   int32 = 2
 	WinWidth, WinHeight  int32 = 320 * mn, 180 * mn
-	row, column, mines   int32 = 8, 8, 10
 	Background                 = sdl.Color{0, 129, 110, 255}
 	Foreground                 = sdl.Color{223, 225, 81, 255}
 	BackgroundStatusLine       = sdl.Color{0, 64, 32, 255}
 	ForegroundStatusLine       = sdl.Color{255, 0, 64, 255}
 	StatusLineFontSize   int   = int(StatusLineHeight) - 3
 	StatusLineHeight     int32 = WinHeight / 20
+	fontPath                   = config.Font
 )
 
 /*
@@ -232,16 +354,54 @@ o            8             8
 :::::::::::::::::::::::::::::
 :::::::::::::::::::::::::::::*/
 
+var fontFileFailed bool
+
+// Шрифт из файла в настройках, без него или при ошибке Roboto из ресурсов
+func openFont(size int) (*ttf.Font, error) {
+	if fontPath != "" {
+		font, err := ttf.OpenFont(fontPath, size)
+		if err == nil {
+			return font, nil
+		}
+		if !fontFileFailed {
+			fontFileFailed = true
+			logUI.Warn("font, using the built-in one", "path", fontPath, "err", err)
+		}
+	}
+	rw, err := fontRW()
+	if err != nil {
+		return nil, fmt.Errorf("font %v: %w", fontAsset, err)
+	}
+	return ttf.OpenFontRW(rw, 1, size)
+}
+
+// Без шрифта надпись пустая, View.Setup проверяет шрифт заранее и до этого не доходит
 func (s *Label) Setup(pos sdl.Point, text string, fontSize int, fg sdl.Color) {
 	s.rect = sdl.Rect{pos.X, pos.Y, 1, 1}
 	s.fontSize = fontSize
 	s.text = text
 	s.fg = fg
 	var err error
-	fontName := "assets/Roboto-Regular.ttf"
-	if s.font, err = ttf.OpenFont(fontName, s.fontSize); err != nil {
-		panic(err)
+	if s.font, err = openFont(s.fontSize); err != nil {
+		logUI.Error("label", "text", text, "err", err)
+	}
+}
+
+// Ширина текста в пикселях, нужна чтобы подогнать кнопки под перевод. Без шрифта примерная
+func textWidth(text string, fontSize int) int32 {
+	estimate := int32(len([]rune(text)) * fontSize / 2)
+	font, err := openFont(fontSize)
+	if err != nil {
+		logUI.Error("text width", "err", err)
+		return estimate
 	}
+	defer font.Close()
+	w, _, err := font.SizeUTF8(text)
+	if err != nil {
+		logUI.Error("text width", "text", text, "err", err)
+		return estimate
+	}
+	return int32(w)
 }
 
 func (s *Label) GetLabel() string {
@@ -274,12 +434,17 @@ func (s *Label) Render(renderer *sdl.Renderer) {
 		surface *sdl.Surface
 		texture *sdl.Texture
 	)
+	if s.font == nil || s.text == "" {
+		return
+	}
 	if surface, err = s.font.RenderUTF8Blended(s.text, s.fg); err != nil {
-		panic(err)
+		logUI.Error("label render", "text", s.text, "err", err)
+		return
 	}
 	defer surface.Free()
 	if texture, err = renderer.CreateTextureFromSurface(surface); err != nil {
-		panic(err)
+		logUI.Error("label texture", "text", s.text, "err", err)
+		return
 	}
 	_, _, s.rect.W, s.rect.H, _ = texture.Query()
 	defer texture.Destroy()
@@ -287,7 +452,9 @@ func (s *Label) Render(renderer *sdl.Renderer) {
 }
 
 func (t *Label) Destroy() {
-	t.font.Close()
+	if t.font != nil {
+		t.font.Close()
+	}
 }
 
 /*
@@ -300,8 +467,12 @@ o     o                             .oPYo.
 ..::::..:.....::.....::.....::.....::.....::.....:..:::::.....::.....:..::::
 ::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::
 ::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::*/
+
+// Положение мыши, в тестах без окна подменяется
+var mouseState = sdl.GetMouseState
+
 func (s *MouseCursor) Update() (int32, int32, uint32) {
-	s.X, s.Y, s.button = sdl.GetMouseState()
+	s.X, s.Y, s.button = mouseState()
 	return s.X, s.Y, s.button
 }
 
@@ -379,19 +550,19 @@ func (s *Button) Event(event sdl.Event) Event {
 	case *sdl.MouseButtonEvent:
 		if s.mouse.InRect(s.GetRect()) && t.Button == sdl.BUTTON_LEFT && t.State == sdl.PRESSED {
 			s.pressed = true
-			log.Printf("Button: SEND left mouse button pressed:%v\n", s.text)
+			logInput.Debug("button pressed", "mouse", "left", "text", s.text)
 			return MouseButtonLeftPressedEvent
 		} else if s.mouse.InRect(s.GetRect()) && t.Button == sdl.BUTTON_LEFT && t.State == sdl.RELEASED {
 			s.pressed = false
-			log.Printf("Button: SEND left mouse button released:%v\n", s.text)
+			logInput.Debug("button released", "mouse", "left", "text", s.text)
 			return MouseButtonLeftReleasedEvent
 		} else if s.mouse.InRect(s.GetRect()) && t.Button == sdl.BUTTON_RIGHT && t.State == sdl.PRESSED {
 			s.pressed = true
-			log.Printf("Button: SEND right mouse button pressed:%v\n", s.text)
+			logInput.Debug("button pressed", "mouse", "right", "text", s.text)
 			return MouseButtonRightPressedEvent
 		} else if s.mouse.InRect(s.GetRect()) && t.Button == sdl.BUTTON_RIGHT && t.State == sdl.RELEASED {
 			s.pressed = false
-			log.Printf("Button: SEND right mouse button released:%v\n", s.text)
+			logInput.Debug("button released", "mouse", "right", "text", s.text)
 			return MouseButtonRightReleasedEvent
 		}
 	}
@@ -451,7 +622,7 @@ func (s *MessageBox) Setup(rect sdl.Rect, title, message string, fg, bg sdl.Colo
 	s.bg = bg
 	s.titleLabel.Setup(sdl.Point{s.rect.X + 5, s.rect.Y + 3}, s.title, 10, s.fg)
 	s.messageLabel.Setup(sdl.Point{s.rect.X + 30, s.rect.Y + 50}, s.message, 30, s.fg)
-	s.okButton.Setup(sdl.Rect{(s.rect.W - 100) / 2, s.rect.H - 25, 100, 20}, sdl.Point{s.rect.X, s.rect.Y}, "Ok", 20, s.fg, s.bg)
+	s.okButton.Setup(sdl.Rect{(s.rect.W - 100) / 2, s.rect.H - 25, 100, 20}, sdl.Point{s.rect.X, s.rect.Y}, tr("ok"), 20, s.fg, s.bg)
 	s.Hide = false
 
 }
@@ -469,6 +640,24 @@ func (b *MessageBox) SetText(value string) {
 	b.messageLabel.SetLabel(value)
 }
 
+// Строки итогов под сообщением, окно растет вниз под их число
+func (b *MessageBox) SetDetails(lines []string) {
+	for _, label := range b.details {
+		label.Destroy()
+	}
+	b.details = nil
+	for i, line := range lines {
+		label := &Label{}
+		label.Setup(sdl.Point{b.rect.X + 30, b.rect.Y + 90 + int32(i)*16}, line, 14, b.fg)
+		b.details = append(b.details, label)
+	}
+	b.rect.H = 150
+	if len(lines) > 2 {
+		b.rect.H += int32(len(lines)-2) * 16
+	}
+	b.okButton.rect.Y = b.rect.H - 25
+}
+
 func (b *MessageBox) Render(renderer *sdl.Renderer) (err error) {
 	renderer.SetDrawColor(b.bg.R, b.bg.G, b.bg.B, b.bg.A)
 	renderer.FillRect(&b.rect)
@@ -477,6 +666,9 @@ func (b *MessageBox) Render(renderer *sdl.Renderer) (err error) {
 	renderer.DrawRect(&b.rect)
 	b.titleLabel.Render(renderer)
 	b.messageLabel.Render(renderer)
+	for _, label := range b.details {
+		label.Render(renderer)
+	}
 	b.okButton.Render(renderer)
 	return nil
 }
@@ -494,6 +686,9 @@ func (b *MessageBox) Event(event sdl.Event) (pressed bool) {
 func (b *MessageBox) Destroy() {
 	b.titleLabel.Destroy()
 	b.messageLabel.Destroy()
+	for _, label := range b.details {
+		label.Destroy()
+	}
 	b.okButton.Destroy()
 }
 
@@ -529,7 +724,7 @@ func (t *Arrow) New(rect sdl.Rect, text string, fgColor, bgColor sdl.Color, font
 			t.btnInstances = append(t.btnInstances, btn)
 		case label:
 			lbl := &Label{}
-			lbl.Setup(sdl.Point{relativePos.X + button.rect.X, button.rect.Y}, button.text, fontSize, t.fgColor)
+			lbl.Setup(sdl.Point{relativePos.X + button.rect.X, relativePos.Y + button.rect.Y}, button.text, fontSize, t.fgColor)
 			t.btnInstances = append(t.btnInstances, lbl)
 		}
 	}
@@ -545,9 +740,13 @@ func (t *Arrow) GetLabel() string {
 	return t.text
 }
 
-func (s *Arrow) GetNumber() (value []int) {
+// Число и процент из надписи вида "mines:10:%:12"
+func (s *Arrow) GetNumber() (value []int, err error) {
 	var num, percent string
 	arr := strings.Split(s.GetLabel(), ":")
+	if len(arr) != 2 && len(arr) != 4 {
+		return nil, fmt.Errorf("arrow label %q", s.GetLabel())
+	}
 	num = arr[1]
 	if len(arr) > 2 {
 		percent = arr[3]
@@ -556,14 +755,14 @@ func (s *Arrow) GetNumber() (value []int) {
 	}
 	valueNum, err := strconv.Atoi(num)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("arrow label %q: %w", s.GetLabel(), err)
 	}
 	valuePerc, err := strconv.Atoi(percent)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("arrow label %q: %w", s.GetLabel(), err)
 	}
 	value = append(value, valueNum, valuePerc)
-	return value
+	return value, nil
 }
 
 func (s *Arrow) SetNumber(value []int) {
@@ -586,10 +785,10 @@ func (s *Arrow) Event(event sdl.Event) (e Event) {
 					for i := 0; i < len(s.buttons[idx].event); i++ {
 						switch s.buttons[idx].event[i] {
 						case DecButtonEvent:
-							log.Println("Arrow:released dec", ok)
+							logInput.Debug("arrow", "button", "dec")
 							return DecButtonEvent
 						case IncButtonEvent:
-							log.Println("Arrow:released inc", ok)
+							logInput.Debug("arrow", "button", "inc")
 							return IncButtonEvent
 						}
 					}
@@ -651,13 +850,14 @@ func (s *StatusLine) Setup() {
 		s.btnInstances = nil
 	}
 	s.buttons = []buttonsData{
-		{name: buttonQuit, rect: sdl.Rect{0, 0, StatusLineHeight, StatusLineHeight}, text: "<-", event: []Event{QuitEvent}},
-		{name: buttonPause, rect: sdl.Rect{StatusLineHeight, 0, StatusLineHeight * 3, StatusLineHeight}, text: "Pause", event: []Event{PauseEvent}},
-		{name: buttonReset, rect: sdl.Rect{StatusLineHeight * 4, 0, StatusLineHeight * 3, StatusLineHeight}, text: "Reset", event: []Event{ResetGameEvent}},
-		{name: buttonNew, rect: sdl.Rect{StatusLineHeight * 7, 0, StatusLineHeight * 2, StatusLineHeight}, text: "New", event: []Event{NewGameEvent}},
-		{name: buttonRow, rect: sdl.Rect{StatusLineHeight * 9, 0, StatusLineHeight * 5, StatusLineHeight}, text: "Rows:" + strconv.Itoa(int(s.gameBoardSize.row)), event: []Event{IncRowEvent, DecRowEvent}},
-		{name: buttonCol, rect: sdl.Rect{StatusLineHeight * 15, 0, StatusLineHeight * 6, StatusLineHeight}, text: "Columns:" + strconv.Itoa(int(s.gameBoardSize.column)), event: []Event{IncRowEvent, DecRowEvent}},
-		{name: buttonMines, rect: sdl.Rect{StatusLineHeight * 22, 0, StatusLineHeight * 7, StatusLineHeight}, text: "Mines:" + strconv.Itoa(int(s.gameBoardSize.mines)) + ":%:" + strconv.Itoa(int(s.gameBoardSize.minesPercent)), event: []Event{IncRowEvent, DecRowEvent}}}
+		{name: buttonQuit, text: "<-", event: []Event{QuitEvent}},
+		{name: buttonPause, text: tr("pause"), event: []Event{PauseEvent}},
+		{name: buttonReset, text: tr("reset"), event: []Event{ResetGameEvent}},
+		{name: buttonNew, text: tr("new"), event: []Event{NewGameEvent}},
+		{name: buttonRow, text: tr("rows") + ":" + strconv.Itoa(int(s.gameBoardSize.row)), event: []Event{IncRowEvent, DecRowEvent}},
+		{name: buttonCol, text: tr("columns") + ":" + strconv.Itoa(int(s.gameBoardSize.column)), event: []Event{IncRowEvent, DecRowEvent}},
+		{name: buttonMines, text: tr("mines") + ":" + strconv.Itoa(int(s.gameBoardSize.mines)) + ":%:" + strconv.Itoa(int(s.gameBoardSize.minesPercent)), event: []Event{IncRowEvent, DecRowEvent}}}
+	s.layout()
 	for _, button := range s.buttons {
 		switch button.name {
 		case buttonQuit:
@@ -692,36 +892,46 @@ func (s *StatusLine) Setup() {
 	}
 }
 
+// Ширина кнопок по тексту на текущем языке, стрелки кратны высоте строки и вмещают наибольшее значение
+func (s *StatusLine) layout() {
+	var x int32
+	h := StatusLineHeight
+	widest := map[buttonsType]string{
+		buttonRow:   tr("rows") + ":" + strconv.Itoa(maxRow),
+		buttonCol:   tr("columns") + ":" + strconv.Itoa(maxColumn),
+		buttonMines: tr("mines") + ":" + strconv.Itoa(maxMines) + ":%:99",
+	}
+	for idx, button := range s.buttons {
+		var w int32
+		switch button.name {
+		case buttonQuit:
+			w = h
+		case buttonRow, buttonCol, buttonMines:
+			cells := (textWidth(widest[button.name], StatusLineFontSize) + h - 1) / h
+			w = h * (cells + 2)
+			x += h / 2
+		default:
+			w = textWidth(button.text, StatusLineFontSize) + h
+		}
+		s.buttons[idx].rect = sdl.Rect{x, 0, w, h}
+		x += w
+	}
+}
+
 func (s *StatusLine) GetGameBoardSize() boardConfig {
 	return s.gameBoardSize
 }
 
-func (s *StatusLine) Update(event Event) {
-	switch event {
+func (s *StatusLine) Update(event Message) {
+	switch event.Kind() {
 	case NewGameEvent:
-		log.Printf("start new game:%v", s.gameBoardSize)
-	case IncRowEvent: // Replace game board size by arrows
-		s.gameBoardSize.row = int32(s.btnInstances[4].(*Arrow).GetNumber()[0])
-		s.gameBoardSize.minesPercent = s.gameBoardSize.mines * 100 / (s.gameBoardSize.row * s.gameBoardSize.column)
-	case DecRowEvent:
-		s.gameBoardSize.row = int32(s.btnInstances[4].(*Arrow).GetNumber()[0])
-		s.gameBoardSize.minesPercent = s.gameBoardSize.mines * 100 / (s.gameBoardSize.row * s.gameBoardSize.column)
-	case IncColumnEvent:
-		s.gameBoardSize.column = int32(s.btnInstances[5].(*Arrow).GetNumber()[0])
-		s.gameBoardSize.minesPercent = s.gameBoardSize.mines * 100 / (s.gameBoardSize.row * s.gameBoardSize.column)
-	case DecColumnEvent:
-		s.gameBoardSize.column = int32(s.btnInstances[5].(*Arrow).GetNumber()[0])
-		s.gameBoardSize.minesPercent = s.gameBoardSize.mines * 100 / (s.gameBoardSize.row * s.gameBoardSize.column)
-	case IncMinesEvent:
-		s.gameBoardSize.mines = int32(s.btnInstances[6].(*Arrow).GetNumber()[0])
-		s.gameBoardSize.minesPercent = s.gameBoardSize.mines * 100 / (s.gameBoardSize.row * s.gameBoardSize.column)
-	case DecMinesEvent:
-		s.gameBoardSize.mines = int32(s.btnInstances[6].(*Arrow).GetNumber()[0])
-		s.gameBoardSize.minesPercent = s.gameBoardSize.mines * 100 / (s.gameBoardSize.row * s.gameBoardSize.column)
+		logUI.Info("new game", "size", s.gameBoardSize)
 	case WindowResized:
 		StatusLineHeight = WinHeight / 20
 		StatusLineFontSize = int(StatusLineHeight) - 3
 		s.Setup()
+	case SettingsChangedEvent:
+		s.Setup()
 	}
 	for idx, button := range s.btnInstances {
 		switch button.(type) {
@@ -730,7 +940,7 @@ func (s *StatusLine) Update(event Event) {
 			if s.buttons[idx].name == buttonNew {
 			}
 		case *Arrow:
-			s.btnInstances[idx].(*Arrow).Update(event)
+			s.btnInstances[idx].(*Arrow).Update(event.Kind())
 		}
 	}
 }
@@ -747,7 +957,12 @@ func (s *StatusLine) Render(renderer *sdl.Renderer) {
 	}
 }
 
-func (s *StatusLine) Event(event sdl.Event) (e Event) {
+func (s *StatusLine) Event(event sdl.Event) (e Message) {
+	// E открывает редактор на размере из строки статуса, как New новую игру
+	if key, ok := event.(*sdl.KeyboardEvent); ok && key.Keysym.Sym == sdl.K_e && key.State == sdl.RELEASED {
+		logInput.Debug("key", "key", "E", "event", "editor", "size", s.gameBoardSize)
+		return BoardEvent{EditorToggleEvent, s.gameBoardSize}
+	}
 	for idx, button := range s.btnInstances {
 		switch event.(type) {
 		case *sdl.MouseButtonEvent:
@@ -758,50 +973,52 @@ func (s *StatusLine) Event(event sdl.Event) (e Event) {
 					for i := 0; i < len(s.buttons[idx].event); i++ {
 						switch s.buttons[idx].event[i] {
 						case QuitEvent:
-							log.Println("Get QuitEvent", s.buttons[idx].name)
+							logInput.Debug("status line", "event", "quit")
 							return QuitEvent
 						case PauseEvent:
-							log.Println("Get PauseEvent", s.buttons[idx].name)
+							logInput.Debug("status line", "event", "pause")
 							return PauseEvent
 						case ResetGameEvent:
-							log.Println("Get ResetEvent", s.buttons[idx].name)
+							logInput.Debug("status line", "event", "reset")
 							return ResetGameEvent
 						case NewGameEvent:
-							log.Println("Get NewEvent", s.buttons[idx].name)
-							return NewGameEvent
+							logInput.Debug("status line", "event", "new", "size", s.gameBoardSize)
+							return BoardEvent{NewGameEvent, s.gameBoardSize}
 						}
 					}
 				}
 
 			case *Arrow:
 				if ev := button.(*Arrow).Event(event); ev != NilEvent {
+					op := "inc"
+					if ev == DecButtonEvent {
+						op = "dec"
+					}
+					if err := s.calc(s.buttons[idx].name, s.btnInstances[idx].(*Arrow), op); err != nil {
+						logUI.Error("status line", "err", err)
+						return NilEvent
+					}
 					switch s.buttons[idx].name {
 					case buttonRow:
 						switch ev {
 						case IncButtonEvent:
-							s.calc(s.buttons[idx].name, s.btnInstances[idx].(*Arrow), "inc")
-							return IncRowEvent
+							return BoardEvent{IncRowEvent, s.gameBoardSize}
 						case DecButtonEvent:
-							s.calc(s.buttons[idx].name, s.btnInstances[idx].(*Arrow), "dec")
-							return DecRowEvent
+							return BoardEvent{DecRowEvent, s.gameBoardSize}
 						}
 					case buttonCol:
 						switch ev {
 						case IncButtonEvent:
-							s.calc(s.buttons[idx].name, s.btnInstances[idx].(*Arrow), "inc")
-							return IncColumnEvent
+							return BoardEvent{IncColumnEvent, s.gameBoardSize}
 						case DecButtonEvent:
-							s.calc(s.buttons[idx].name, s.btnInstances[idx].(*Arrow), "dec")
-							return DecColumnEvent
+							return BoardEvent{DecColumnEvent, s.gameBoardSize}
 						}
 					case buttonMines:
 						switch ev {
 						case IncButtonEvent:
-							s.calc(s.buttons[idx].name, s.btnInstances[idx].(*Arrow), "inc")
-							return IncMinesEvent
+							return BoardEvent{IncMinesEvent, s.gameBoardSize}
 						case DecButtonEvent:
-							s.calc(s.buttons[idx].name, s.btnInstances[idx].(*Arrow), "dec")
-							return DecMinesEvent
+							return BoardEvent{DecMinesEvent, s.gameBoardSize}
 						}
 					}
 				}
@@ -811,8 +1028,11 @@ func (s *StatusLine) Event(event sdl.Event) (e Event) {
 	return NilEvent
 }
 
-func (s *StatusLine) calc(name buttonsType, instance *Arrow, op string) {
-	n := instance.GetNumber()
+func (s *StatusLine) calc(name buttonsType, instance *Arrow, op string) error {
+	n, err := instance.GetNumber()
+	if err != nil {
+		return err
+	}
 	switch op {
 	case "inc":
 		switch name {
@@ -827,7 +1047,7 @@ func (s *StatusLine) calc(name buttonsType, instance *Arrow, op string) {
 				n[1] = int(s.gameBoardSize.minesPercent)
 			}
 		case buttonMines:
-			if n[0] < maxMines {
+			if n[0] < maxMines && int32(n[0]) < s.gameBoardSize.row*s.gameBoardSize.column-1 {
 				n[0]++
 				n[1] = int(s.gameBoardSize.minesPercent)
 			}
@@ -852,9 +1072,24 @@ func (s *StatusLine) calc(name buttonsType, instance *Arrow, op string) {
 		}
 	}
 	instance.SetNumber(n)
-	m := s.btnInstances[6].(*Arrow).GetNumber()
-	m[1] = n[1]
+	switch name {
+	case buttonRow:
+		s.gameBoardSize.row = int32(n[0])
+	case buttonCol:
+		s.gameBoardSize.column = int32(n[0])
+	case buttonMines:
+		s.gameBoardSize.mines = int32(n[0])
+	}
+	// на уменьшенном поле хотя бы одна ячейка остается без мины
+	s.gameBoardSize.mines = clamp(s.gameBoardSize.mines, minMines, s.gameBoardSize.row*s.gameBoardSize.column-1)
+	s.gameBoardSize.minesPercent = s.gameBoardSize.mines * 100 / (s.gameBoardSize.row * s.gameBoardSize.column)
+	m, err := s.btnInstances[6].(*Arrow).GetNumber()
+	if err != nil {
+		return err
+	}
+	m[0], m[1] = int(s.gameBoardSize.mines), int(s.gameBoardSize.minesPercent)
 	s.btnInstances[6].(*Arrow).SetNumber(m)
+	return nil
 }
 
 func (s *StatusLine) Destroy() {
@@ -884,7 +1119,12 @@ func (s *StatusLine) Destroy() {
 func (s *GameBoard) New(b boardConfig, start bool) {
 	s.start = start
 	s.gameBoardSize = b
-	s.colors = []sdl.Color{sdl.Color{192, 192, 192, 255}, sdl.Color{0, 0, 255, 255}, sdl.Color{0, 128, 0, 255}, sdl.Color{255, 0, 0, 255}, sdl.Color{0, 0, 128, 255}, sdl.Color{128, 0, 0, 255}, sdl.Color{0, 128, 128, 255}, sdl.Color{0, 0, 0, 255}, sdl.Color{128, 128, 128, 255}}
+	s.colors = getPalette(config.Theme)
+	s.board, s.stat = nil, nil
+	s.preview = -1
+	s.summary = nil
+	s.paused = false
+	s.targets = nil
 	s.Setup()
 }
 
@@ -930,11 +1170,12 @@ func (s *GameBoard) Setup() {
 	}
 	board = nil
 	s.messageBox = &MessageBox{}
-	s.messageBox.Setup(sdl.Rect{WinWidth/2 - 300/2, WinHeight/2 - 150/2, 300, 150}, "Message", "Test Message", s.colors[1], s.colors[8])
+	s.messageBox.Setup(sdl.Rect{WinWidth/2 - 360/2, WinHeight/2 - 150/2, 360, 150}, tr("message"), "Test Message", s.colors[1], s.colors[8])
+	s.messageBox.SetDetails(s.summary)
 	s.messageBox.Hide = true
 	s.btnInstances = append(s.btnInstances, s.messageBox)
 
-	text := fmt.Sprintf("F:%v/M:%v", 0, strconv.Itoa(int(s.gameBoardSize.mines)))
+	text := fmt.Sprintf(tr("flags_mines"), 0, strconv.Itoa(int(s.gameBoardSize.mines)))
 
 	arr := []string{text, "00:00"}
 	for dx = 0; dx < int32(len(arr)); dx++ {
@@ -953,12 +1194,13 @@ func (s *GameBoard) SetButton(idx int, cell string, fg, bg sdl.Color) {
 	s.btnInstances[idx].(*Button).SetForeground(fg)
 }
 func (s *GameBoard) SetBoard(board []int32, stat []int) {
+	s.board, s.stat = board, stat
 	for idx, button := range s.btnInstances {
 		switch button.(type) {
 		case *Button:
 			switch board[idx] {
 			case wrongMines:
-				s.SetButton(idx, strconv.Itoa(int(board[idx])), s.colors[7], s.colors[8])
+				s.SetButton(idx, "X", s.colors[3], s.colors[0])
 			case 0:
 				s.SetButton(idx, " ", s.colors[7], s.colors[0])
 			case 1, 2, 3, 4, 5, 6, 7, 8:
@@ -979,39 +1221,106 @@ func (s *GameBoard) SetBoard(board []int32, stat []int) {
 				s.SetButton(idx, "b", s.colors[7], s.colors[0])
 			}
 		case *MessageBox:
+			s.paused = board[idx] == pause
 			switch board[idx] {
 			case play:
-				log.Println("play", idx)
+				logUI.Debug("message box", "state", "play")
 				s.btnInstances[idx].(*MessageBox).Hide = true
 			case pause:
-				s.btnInstances[idx].(*MessageBox).SetText("Pause")
+				s.btnInstances[idx].(*MessageBox).SetText(tr("pause"))
 				s.btnInstances[idx].(*MessageBox).Hide = false
-				log.Println("pause", idx)
+				logUI.Debug("message box", "state", "pause")
 			case won:
-				s.btnInstances[idx].(*MessageBox).SetText("You Win")
+				s.btnInstances[idx].(*MessageBox).SetText(tr("you_win"))
 				s.btnInstances[idx].(*MessageBox).Hide = false
-				log.Println("win", idx)
+				logUI.Debug("message box", "state", "won")
 			case lost:
-				s.btnInstances[idx].(*MessageBox).SetText("Game Over")
+				s.btnInstances[idx].(*MessageBox).SetText(tr("game_over"))
 				s.btnInstances[idx].(*MessageBox).Hide = false
-				log.Println("game over", idx)
+				logUI.Debug("message box", "state", "lost")
 			}
 		case *Label:
-			text := fmt.Sprintf("F:%v/M:%v", strconv.Itoa(stat[1]), strconv.Itoa(stat[0]-stat[1]))
+			text := fmt.Sprintf(tr("flags_mines"), strconv.Itoa(stat[1]), strconv.Itoa(stat[0]-stat[1]))
 			s.btnInstances[len(s.btnInstances)-2].(*Label).SetLabel(text)
 		}
 	}
 }
 
+// Итоги партии в окне сообщения
+func (s *GameBoard) SetSummary(lines []string) {
+	s.summary = lines
+	s.messageBox.SetDetails(lines)
+}
+
 func (s *GameBoard) SetTimer(timer []uint32) {
 	text := fmt.Sprintf("%02v:%02v", strconv.Itoa(int(timer[1])), strconv.Itoa(int(timer[0])))
+	s.SetTimerText(text)
+}
+
+// Ячейки-цели головоломки обводятся рамкой
+func (s *GameBoard) SetTargets(targets map[int32]bool) {
+	s.targets = targets
+}
+
+// Надписи под полем вместо флагов и времени, в редакторе там 3BV и решаемость
+func (s *GameBoard) SetInfo(left, right string) {
+	s.btnInstances[len(s.btnInstances)-2].(*Label).SetLabel(left)
+	s.btnInstances[len(s.btnInstances)-1].(*Label).SetLabel(right)
+}
+
+// Экранный прямоугольник ячейки или nil
+func (s *GameBoard) CellRect(idx int32) *sdl.Rect {
+	if idx < 0 || int(idx) >= len(s.btnInstances) {
+		return nil
+	}
+	if button, ok := s.btnInstances[idx].(*Button); ok {
+		return button.GetRect()
+	}
+	return nil
+}
+
+func (s *GameBoard) SetTimerText(text string) {
 	s.btnInstances[len(s.btnInstances)-1].(*Label).SetLabel(text)
 }
 
-func (s *GameBoard) Update(event Event) {
-	if event == WindowResized {
-		log.Println("resize gameBoard")
+func (s *GameBoard) Update(event Message) {
+	switch event.Kind() {
+	case FieldChangedEvent:
+		field := event.(FieldEvent)
+		if field.Fresh {
+			s.New(field.Size, true)
+		}
+		s.SetTargets(field.Targets)
+		s.SetBoard(field.Values, field.Stat)
+	case TimerChangedEvent:
+		s.SetTimer(event.(TimerEvent).Timer)
+	case InfoChangedEvent:
+		info := event.(InfoEvent)
+		s.SetInfo(info.Left, info.Right)
+	case GameEndEvent:
+		outcome := event.(OutcomeEvent)
+		s.SetSummary(outcome.Summary)
+		if outcome.State == gameWin {
+			s.SetTimerText(outcome.Time)
+		}
+	case WindowResized:
+		logUI.Debug("resize board", "width", WinWidth, "height", WinHeight)
+		s.Setup()
+	case NextPaletteEvent:
+		config.Theme = nextPalette(config.Theme)
+		logUI.Info("palette", "theme", config.Theme)
+		saveConfig()
+		fallthrough
+	case SettingsChangedEvent:
+		s.colors = getPalette(config.Theme)
 		s.Setup()
+		if s.board != nil {
+			s.SetBoard(s.board, s.stat)
+		}
+	case GlyphsToggleEvent:
+		config.Glyphs = !config.Glyphs
+		logUI.Info("glyphs", "on", config.Glyphs)
+		saveConfig()
 	}
 	for idx, button := range s.btnInstances {
 		switch button.(type) {
@@ -1023,11 +1332,52 @@ func (s *GameBoard) Update(event Event) {
 	}
 }
 
+// Закрытые ячейки вокруг курсора, пока зажат аккорд
+func (s *GameBoard) chordPreview() map[int]bool {
+	cells := map[int]bool{}
+	if s.preview < 0 || s.board == nil {
+		return cells
+	}
+	x0, y0 := s.preview%s.gameBoardSize.row, s.preview/s.gameBoardSize.row
+	for y := y0 - 1; y <= y0+1; y++ {
+		for x := x0 - 1; x <= x0+1; x++ {
+			if x < 0 || y < 0 || x >= s.gameBoardSize.row || y >= s.gameBoardSize.column {
+				continue
+			}
+			if idx := int(y*s.gameBoardSize.row + x); s.board[idx] == closed || s.board[idx] == questionable {
+				cells[idx] = true
+			}
+		}
+	}
+	return cells
+}
+
 func (s *GameBoard) Render(renderer *sdl.Renderer) {
-	for _, button := range s.btnInstances {
+	preview := s.chordPreview()
+	for idx, button := range s.btnInstances {
 		switch button.(type) {
 		case *Button:
-			button.(*Button).Render(renderer)
+			if s.paused { // на паузе поле закрыто целиком, чтобы не разглядывать цифры
+				rect := button.(*Button).GetRect()
+				renderer.SetDrawColor(s.colors[8].R, s.colors[8].G, s.colors[8].B, s.colors[8].A)
+				renderer.FillRect(rect)
+				renderer.SetDrawColor(s.colors[7].R, s.colors[7].G, s.colors[7].B, s.colors[7].A)
+				renderer.DrawRect(rect)
+				continue
+			}
+			if preview[idx] {
+				button.(*Button).paint(renderer, s.colors[7], s.colors[0])
+			} else {
+				button.(*Button).Render(renderer)
+			}
+			if idx < len(s.board) && (config.Glyphs || s.board[idx] == wrongMines) {
+				drawGlyph(renderer, button.(*Button).GetRect(), s.board[idx], s.colors[7])
+			}
+			if s.targets[int32(idx)] {
+				rect := button.(*Button).GetRect()
+				renderer.SetDrawColor(s.colors[3].R, s.colors[3].G, s.colors[3].B, s.colors[3].A)
+				renderer.DrawRect(&sdl.Rect{rect.X + 2, rect.Y + 2, rect.W - 4, rect.H - 4})
+			}
 		case *Label:
 			button.(*Label).Render(renderer)
 		case *MessageBox:
@@ -1038,28 +1388,89 @@ func (s *GameBoard) Render(renderer *sdl.Renderer) {
 	}
 }
 
-func (s *GameBoard) Event(event sdl.Event) (e Event) {
+// Индекс ячейки под точкой экрана или -1
+func (s *GameBoard) cellAt(x, y int32) int32 {
+	for idx, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			if (&sdl.Point{x, y}).InRect(button.(*Button).GetRect()) {
+				return int32(idx)
+			}
+		}
+	}
+	return -1
+}
+
+// Аккорд: обе кнопки вместе или средняя, срабатывает при отпускании первой,
+// отпускание второй кнопки после аккорда игнорируется
+func (s *GameBoard) mouseButton(t *sdl.MouseButtonEvent) Message {
+	idx := s.cellAt(t.X, t.Y)
+	if t.State == sdl.PRESSED {
+		switch t.Button {
+		case sdl.BUTTON_LEFT:
+			s.leftDown = true
+		case sdl.BUTTON_RIGHT:
+			s.rightDown = true
+		}
+		if config.Chord != "off" && (t.Button == sdl.BUTTON_MIDDLE || s.leftDown && s.rightDown) {
+			s.chording = true
+			s.preview = idx
+		}
+		return NilEvent
+	}
+	switch t.Button {
+	case sdl.BUTTON_LEFT:
+		s.leftDown = false
+	case sdl.BUTTON_RIGHT:
+		s.rightDown = false
+	}
+	if s.chording {
+		s.chording, s.preview = false, -1
+		s.chordDone = s.leftDown || s.rightDown
+		if idx < 0 {
+			return NilEvent
+		}
+		logInput.Debug("chord", "cell", idx)
+		return CellEvent{ChordEvent, idx}
+	}
+	if s.chordDone {
+		s.chordDone = s.leftDown || s.rightDown
+		return NilEvent
+	}
+	if idx < 0 {
+		return NilEvent
+	}
+	switch t.Button {
+	case sdl.BUTTON_LEFT:
+		return CellEvent{MouseButtonLeftReleasedEvent, idx}
+	case sdl.BUTTON_RIGHT:
+		return CellEvent{MouseButtonRightReleasedEvent, idx}
+	}
+	return NilEvent
+}
+
+func (s *GameBoard) Event(event sdl.Event) (e Message) {
+	switch t := event.(type) {
+	case *sdl.MouseMotionEvent:
+		if s.chording {
+			s.preview = s.cellAt(t.X, t.Y)
+		}
+	case *sdl.MouseButtonEvent:
+		if s.messageBox.Hide {
+			return s.mouseButton(t)
+		}
+	}
 	for idx, button := range s.btnInstances {
 		switch t := event.(type) {
 		case *sdl.MouseButtonEvent:
 			switch button.(type) {
-			case *Button:
-				ok := button.(*Button).Event(event)
-				if ok == MouseButtonLeftReleasedEvent && s.messageBox.Hide {
-					s.mousePressedAtButton = int32(idx)
-					return MouseButtonLeftReleasedEvent
-				}
-				if ok := button.(*Button).Event(event); ok == MouseButtonRightReleasedEvent && s.messageBox.Hide {
-					s.mousePressedAtButton = int32(idx)
-					return MouseButtonRightReleasedEvent
-				}
 			case *MessageBox:
 				if ok := button.(*MessageBox).Event(event); ok {
-					if button.(*MessageBox).GetText() == "Pause" {
+					if button.(*MessageBox).GetText() == tr("pause") {
 						return PauseEvent
 					}
 					s.btnInstances[idx].(*MessageBox).Hide = true
-					log.Printf("%v MessageBox ok released:%v %v %v\n", idx, t.X, t.Y, button)
+					logInput.Debug("message box ok", "x", t.X, "y", t.Y)
 				}
 			}
 		}
@@ -1092,64 +1503,75 @@ ooooo  o
 ::::::::::::::::::::::::::::::
 ::::::::::::::::::::::::::::::*/
 
+func (s *Timer) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
+}
+
+// Обнулить, отсчет начнется с первого хода
 func (s *Timer) Reset() {
-	s.running = true
-	s.pause = true
-	s.startTick = s.update()
-	s.mSec = 0
-	s.seconds = 0
+	s.elapsed = 0
+	s.started = false
+	s.running = false
 }
 
 func (s *Timer) Start() {
-	s.pause = false
-	s.startTick = s.update()
-	s.nowTick = 0
-	s.pause = false
+	if !s.running {
+		s.startTime = s.now()
+		s.started = true
+		s.running = true
+	}
 }
 
 func (s *Timer) IsPause() bool {
-	return s.pause
+	return !s.running
 }
 
+// Пауза копит прошедшее время, пока стоит, время не идет
 func (s *Timer) Pause() {
-	s.pause = true
+	if s.running {
+		s.elapsed += s.now().Sub(s.startTime)
+		s.running = false
+	}
+}
+
+// Продолжить после паузы, если отсчет уже начинался
+func (s *Timer) Resume() {
+	if s.started {
+		s.Start()
+	}
 }
 
 func (s *Timer) Stop() {
+	s.Pause()
+}
+
+func (s *Timer) Elapsed() time.Duration {
 	if s.running {
-		s.running = false
+		return s.elapsed + s.now().Sub(s.startTime)
 	}
+	return s.elapsed
 }
 
-func (s *Timer) update() uint32 {
-	// return uint32(time.Now().Nanosecond() / 1000000)
-	return sdl.GetTicks()
+func (s *Timer) Seconds() float64 {
+	return s.Elapsed().Seconds()
 }
 
-func (s *Timer) Update() {
-	if s.running && !s.pause {
-		var diff uint32
-		s.nowTick = s.update()
-		if s.nowTick >= s.startTick {
-			diff = s.nowTick - s.startTick
-		} else {
-			diff = uint32(math.Abs(float64(int(s.startTick - s.nowTick - s.startTick))))
-		}
-		s.mSec += diff
-		if s.mSec >= 1000 {
-			s.mSec -= 1000
-			s.seconds++
-		}
-		s.startTick = s.nowTick
-	}
+// Время с миллисекундами ss.mmm
+func (s *Timer) Precise() string {
+	ms := s.Elapsed().Milliseconds()
+	return fmt.Sprintf("%02d.%03d", ms/1000, ms%1000)
 }
 
 func (s *Timer) GetTimer() (str string, arr []uint32) {
 	var second, minute, hour, day uint32
-	second = s.seconds % 60
-	minute = s.seconds % 3600 / 60
-	hour = s.seconds % 86400 / 3600
-	day = s.seconds / 86400
+	seconds := uint32(s.Elapsed() / time.Second)
+	second = seconds % 60
+	minute = seconds % 3600 / 60
+	hour = seconds % 86400 / 3600
+	day = seconds / 86400
 	if day > 0 {
 		str = fmt.Sprintf("day:%v/%v:%v:%v", day, hour, minute, second)
 	} else if day == 0 && hour > 0 {
@@ -1262,12 +1684,13 @@ func (s *Cell) Open() {
 	}
 }
 
-func (s *Cell) MarkFlag() {
+// Правая кнопка: закрыта -> флаг -> вопрос -> закрыта, без вопросов флаг снимается сразу
+func (s *Cell) MarkFlag(questionMarks bool) {
 	if s.state == closed {
 		s.state = flagged
-	} else if s.state == flagged {
+	} else if s.state == flagged && questionMarks {
 		s.state = questionable
-	} else if s.state == questionable {
+	} else if s.state == flagged || s.state == questionable {
 		s.state = closed
 	}
 }
@@ -1324,16 +1747,49 @@ func (s *Field) New(boardSize boardConfig) (err error) {
 			s.field = append(s.field, cell)
 		}
 	}
+	s.bbbv, s.clicks, s.wastedClicks = 0, [3]int32{}, 0
+	s.waves = nil
+	s.targets = nil
 	s.SetState(gameStart)
 	return nil
 }
 
+// Одинаковое зерно и первый ход дают одинаковое поле, 0 каждый раз новое поле
+func (s *Field) SetSeed(seed int64) {
+	s.seed = seed
+}
+
+// Защита первого хода: cell только сама ячейка, opening ячейка с соседями, off без защиты
+func (s *Field) SetFirstClick(mode string) {
+	s.firstClick = mode
+}
+
 func (s *Field) Setup(firstMoveIdx int32) {
 	var mines, x, y int32
-	firstMovePos, _ := s.getPosOfCell(firstMoveIdx)
+	intn := rand.Intn
+	if s.seed != 0 {
+		intn = rand.New(rand.NewSource(s.seed)).Intn
+	}
+	firstMovePos, firstCell := s.getPosOfCell(firstMoveIdx)
+	safe := map[*Cell]bool{}
+	switch s.firstClick {
+	case "off":
+	case "opening":
+		if neighbours := s.getNeighbours(firstMovePos.X, firstMovePos.Y); int32(len(s.field)-len(neighbours)) >= s.boardSize.mines {
+			for _, cell := range neighbours {
+				safe[cell] = true
+			}
+			break
+		}
+		fallthrough
+	default:
+		safe[firstCell] = true
+	}
+	// мин не больше, чем свободных ячеек, иначе расстановка не кончится
+	s.boardSize.mines = clamp(s.boardSize.mines, 0, int32(len(s.field)-len(safe)))
 	for mines < s.boardSize.mines {
-		x, y = int32(rand.Intn(int(s.boardSize.row))), int32(rand.Intn(int(s.boardSize.column)))
-		if x == firstMovePos.X && y == firstMovePos.Y {
+		x, y = int32(intn(int(s.boardSize.row))), int32(intn(int(s.boardSize.column)))
+		if _, cell := s.getIdxOfCell(x, y); safe[cell] {
 			continue
 		}
 		_, cell := s.getIdxOfCell(x, y)
@@ -1342,6 +1798,12 @@ func (s *Field) Setup(firstMoveIdx int32) {
 			mines++
 		}
 	}
+	s.setNumbers()
+	s.SetState(gamePlay)
+}
+
+// Цифры соседних мин для всех пустых ячеек и 3BV готового поля
+func (s *Field) setNumbers() {
 	for idx, cell := range s.field {
 		var count int32
 		if !cell.GetMines() {
@@ -1355,7 +1817,26 @@ func (s *Field) Setup(firstMoveIdx int32) {
 			s.field[idx].SetNumber(count)
 		}
 	}
-	s.SetState(gamePlay)
+	s.bbbv, _ = s.Get3BV()
+}
+
+// Цели головоломки: победа, когда пустые цели открыты, а на минах стоят флаги
+func (s *Field) SetTargets(targets map[int32]bool) {
+	s.targets = targets
+}
+
+func (s *Field) targetsDone() bool {
+	for idx := range s.targets {
+		if cell := &s.field[idx]; cell.GetMines() && !cell.IsFlagged() || !cell.GetMines() && !cell.IsOpened() {
+			return false
+		}
+	}
+	return true
+}
+
+// Номер ячейки в пределах поля
+func (s *Field) isCell(idx int32) bool {
+	return idx >= 0 && idx < int32(len(s.field))
 }
 
 func (s *Field) isFieldEdge(x, y int32) bool {
@@ -1393,74 +1874,198 @@ func (s *Field) Reset() {
 	for idx, _ := range s.field {
 		s.field[idx].Reset()
 	}
+	s.clicks, s.wastedClicks = [3]int32{}, 0
 	s.SetState(gamePlay)
 }
+// Открытие обходом в ширину, номер волны каждой открытой ячейки запоминается для анимации
 func (s *Field) Open(x, y int32) {
 	if s.isFieldEdge(x, y) {
 		return
 	}
-	_, cell := s.getIdxOfCell(x, y)
+	idx, cell := s.getIdxOfCell(x, y)
 	if cell.IsFlagged() || cell.IsOpened() {
 		return
 	}
+	if s.waves == nil {
+		s.waves = map[int32]int32{}
+	}
 	cell.Open()
+	s.waves[idx] = 0
 	if cell.GetMines() {
 		cell.SetFirstMines()
 		s.SetState(gameOver)
 		return
 	}
-	if cell.GetNumber() > 0 {
-		return
+	wave := map[int32]int32{idx: 0}
+	for queue := []int32{idx}; len(queue) > 0; queue = queue[1:] {
+		pos, cell := s.getPosOfCell(queue[0])
+		if cell.GetNumber() > 0 {
+			continue
+		}
+		for _, nCell := range s.getNeighbours(pos.X, pos.Y) {
+			if nCell.IsFlagged() || nCell.IsOpened() {
+				continue
+			}
+			nCell.Open()
+			nIdx, _ := s.getIdxOfCell(nCell.pos.X, nCell.pos.Y)
+			wave[nIdx] = wave[queue[0]] + 1
+			s.waves[nIdx] = wave[nIdx]
+			queue = append(queue, nIdx)
+		}
+	}
+}
+
+// Волны открытия с прошлого вызова
+func (s *Field) TakeWaves() (waves map[int32]int32) {
+	waves, s.waves = s.waves, nil
+	return waves
+}
+
+// Роковая мина и все мины поля
+func (s *Field) mineCells() (first int32, cells []int32) {
+	first = -1
+	for idx, cell := range s.field {
+		if cell.IsFirstMines() {
+			first = int32(idx)
+		}
+		if cell.GetMines() {
+			cells = append(cells, int32(idx))
+		}
+	}
+	return first, cells
+}
+
+// Флаги и закрытые соседи открытой цифры
+func (s *Field) countNeighbours(x, y int32) (cell *Cell, flags, closed int32) {
+	_, cell = s.getIdxOfCell(x, y)
+	if cell == nil || !cell.IsOpened() || cell.GetNumber() <= 0 {
+		return nil, 0, 0
+	}
+	for _, nCell := range s.getNeighbours(x, y) {
+		if nCell.IsFlagged() {
+			flags++
+		} else if nCell.IsClosed() || nCell.IsQuestioned() {
+			closed++
+		}
+	}
+	return cell, flags, closed
+}
+
+// Аккорд: если вокруг цифры стоит столько же флагов, открыть остальных соседей
+func (s *Field) Chord(x, y int32) bool {
+	cell, flags, closed := s.countNeighbours(x, y)
+	if cell == nil || closed == 0 || flags != cell.GetNumber() {
+		return false
+	}
+	for _, nCell := range s.getNeighbours(x, y) {
+		if nCell.IsClosed() || nCell.IsQuestioned() {
+			s.Open(nCell.pos.X, nCell.pos.Y)
+		}
+	}
+	return true
+}
+
+// Подсказка: если закрытых соседей ровно столько, сколько не хватает мин, пометить их флагами
+func (s *Field) AutoFlag(x, y int32) bool {
+	cell, flags, closed := s.countNeighbours(x, y)
+	if cell == nil || closed == 0 || closed+flags != cell.GetNumber() {
+		return false
 	}
 	for _, nCell := range s.getNeighbours(x, y) {
-		s.Open(nCell.pos.X, nCell.pos.Y)
-	}
-}
-
-func (s *Field) autoMarkFlags(x, y int32) {
-	var countFlags, countClosed, countOpened int32
-	_, cell := s.getIdxOfCell(x, y)
-	if cell.IsOpened() {
-		neighbours := s.getNeighbours(x, y)
-		for _, cell := range neighbours {
-			if cell.IsFlagged() {
-				countFlags++
-			} else if cell.IsClosed() {
-				countClosed++
-			} else if cell.IsOpened() {
-				countOpened++
+		if nCell.IsClosed() || nCell.IsQuestioned() {
+			nCell.SetFlagged()
+		}
+	}
+	return true
+}
+
+// 3BV: наименьшее число нажатий для решения, каждая область пустых ячеек с краем из цифр
+// и каждая цифра вне таких областей. solved считает уже открытое
+func (s *Field) Get3BV() (total, solved int32) {
+	seen := make([]bool, len(s.field))
+	for idx := range s.field {
+		if cell := &s.field[idx]; seen[idx] || cell.GetMines() || cell.GetNumber() > 0 {
+			continue
+		}
+		total++
+		opened := false
+		stack := []int32{int32(idx)}
+		seen[idx] = true
+		for len(stack) > 0 {
+			pos, cell := s.getPosOfCell(stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+			opened = opened || cell.IsOpened()
+			if cell.GetNumber() > 0 {
+				continue
+			}
+			for _, nCell := range s.getNeighbours(pos.X, pos.Y) {
+				if nIdx, _ := s.getIdxOfCell(nCell.pos.X, nCell.pos.Y); !seen[nIdx] {
+					seen[nIdx] = true
+					stack = append(stack, nIdx)
+				}
 			}
 		}
+		if opened {
+			solved++
+		}
 	}
-	if countClosed+countFlags == cell.GetNumber() {
-		for _, nCell := range s.getNeighbours(x, y) {
-			if nCell.IsClosed() {
-				nCell.SetFlagged()
+	for idx, cell := range s.field {
+		if !seen[idx] && !cell.GetMines() {
+			total++
+			if cell.IsOpened() {
+				solved++
 			}
 		}
-	} else if countFlags == cell.GetNumber() {
-		for _, nCell := range s.getNeighbours(x, y) {
-			s.Open(nCell.pos.X, nCell.pos.Y)
+	}
+	return total, solved
+}
+
+// Учет нажатия, бесполезное ничего не изменило на поле
+func (s *Field) CountClick(kind clickType, useful bool) {
+	s.clicks[kind]++
+	if !useful {
+		s.wastedClicks++
+	}
+}
+
+// Нажатия по видам и бесполезные
+func (s *Field) GetClicks() (left, right, chord, wasted int32) {
+	return s.clicks[leftClick], s.clicks[rightClick], s.clicks[chordClick], s.wastedClicks
+}
+
+func (s *Field) openedCount() (count int32) {
+	for _, cell := range s.field {
+		if cell.IsOpened() {
+			count++
 		}
 	}
+	return count
+}
+
+func (s *Field) SetQuestionMarks(value bool) {
+	s.questionMarks = value
 }
 
+// Флаги можно ставить и до первого хода, расстановка мин их не трогает
 func (s *Field) MarkFlag(idx int32) {
-	pos, cell := s.getPosOfCell(idx)
-	if s.isFieldEdge(pos.X, pos.Y) {
+	if !s.isCell(idx) {
 		return
 	}
-	cell.MarkFlag()
+	_, cell := s.getPosOfCell(idx)
+	cell.MarkFlag(s.questionMarks)
 }
 
 func (s *Field) isWin() bool {
 	var count int32
+	if s.GetState() == gameOver {
+		return false
+	}
 	for _, cell := range s.field {
 		if cell.IsOpened() {
 			count++
 		}
 	}
-	if count+s.boardSize.mines == s.boardSize.row*s.boardSize.column {
+	if s.targets != nil && s.targetsDone() || s.targets == nil && count+s.boardSize.mines == s.boardSize.row*s.boardSize.column {
 		for idx, cell := range s.field {
 			if cell.GetMines() {
 				s.field[idx].SetSavedMines()
@@ -1480,6 +2085,8 @@ func (s *Field) isGameOver() bool {
 				s.field[idx].SetBlownMines()
 			} else if cell.IsFlagged() && cell.GetMines() {
 				s.field[idx].SetSavedMines()
+			} else if cell.IsFlagged() {
+				s.field[idx].SetWrongMines()
 			}
 		}
 	} else {
@@ -1488,6 +2095,79 @@ func (s *Field) isGameOver() bool {
 	return true
 }
 
+// Верные и ошибочные флаги после проигрыша
+func (s *Field) FlagsSummary() (right, wrong int) {
+	for _, cell := range s.field {
+		if cell.IsSavedMines() {
+			right++
+		} else if cell.IsWrongMines() {
+			wrong++
+		}
+	}
+	return right, wrong
+}
+
+// Поле глазами игрока для решателя: открытые цифры, остальное неизвестно, флагам не верим
+func (s *Field) solverView() (view []int32) {
+	for _, cell := range s.field {
+		if cell.IsOpened() {
+			view = append(view, cell.counter)
+		} else {
+			view = append(view, solverUnknown)
+		}
+	}
+	return view
+}
+
+// Запомнить поле перед ходом, чтобы после проигрыша разобрать роковой ход
+func (s *Field) saveView() {
+	s.lastView = s.solverView()
+}
+
+// Был ли роковой ход вынужденным: forced, avoidable_mine если мина выводилась, avoidable_safe если была безопасная ячейка
+func (s *Field) AnalyzeLoss() string {
+	var fatal int32 = -1
+	for idx, cell := range s.field {
+		if cell.IsFirstMines() {
+			fatal = int32(idx)
+		}
+	}
+	if s.lastView == nil || fatal < 0 {
+		return "forced"
+	}
+	safe, mines := NewSolver(s.boardSize.row, s.boardSize.column, s.boardSize.mines, s.lastView).Deduce()
+	for _, idx := range mines {
+		if idx == fatal {
+			return "avoidable_mine"
+		}
+	}
+	if len(safe) > 0 {
+		return "avoidable_safe"
+	}
+	return "forced"
+}
+
+// Поле без догадок: решатель от открытой стартовой ячейки доходит до конца одними выводами
+func (s *Field) NoGuess(startIdx int32) bool {
+	sim := Field{boardSize: s.boardSize, field: append([]Cell(nil), s.field...)}
+	for idx := range sim.field {
+		sim.field[idx].Reset()
+	}
+	pos, _ := sim.getPosOfCell(startIdx)
+	sim.Open(pos.X, pos.Y)
+	for sim.GetState() != gameOver {
+		safe, _ := NewSolver(s.boardSize.row, s.boardSize.column, s.boardSize.mines, sim.solverView()).Deduce()
+		if len(safe) == 0 {
+			break
+		}
+		for _, idx := range safe {
+			pos, _ := sim.getPosOfCell(idx)
+			sim.Open(pos.X, pos.Y)
+		}
+	}
+	return sim.GetState() != gameOver && sim.openedCount()+s.boardSize.mines == int32(len(sim.field))
+}
+
 func (s *Field) GetFieldValues() (board []int32) {
 	for _, cell := range s.field {
 		if cell.state == closed || cell.state == flagged || cell.state == questionable {
@@ -1513,28 +2193,25 @@ func (s *Field) GetFieldValues() (board []int32) {
 	} else if s.GetState() == gameOver {
 		board = append(board, lost)
 	} else if s.GetState() == gamePause {
-		fmt.Println("game set paused")
 		board = append(board, pause)
 	} else if s.GetState() == gamePlay {
 		board = append(board, play)
 	}
-	log.Println("send board:", board)
+	logEngine.Debug("board", "state", s.GetState(), "values", board)
 	return board
 }
 
 func (s *Field) GetStatistic() (stat []int) {
-	var mines, flags, questions int
+	var flags, questions int
+	mines := int(s.boardSize.mines)
 	for _, cell := range s.field {
-		if cell.GetMines() {
-			mines++
-		}
 		if cell.IsFlagged() || cell.IsSavedMines() {
 			flags++
 		} else if cell.IsQuestioned() {
 			questions++
 		}
 	}
-	fmt.Printf("Get Staticstic mines:%v flags:%v questions:%v\n", mines, flags, questions)
+	logEngine.Debug("statistic", "mines", mines, "flags", flags, "questions", questions)
 	return append(stat, mines, flags, questions)
 }
 
@@ -1575,33 +2252,69 @@ o     o  o
 :::::::::::::::::::::::::::::::*/
 func (s *Mines) New(size boardConfig) {
 	s.field = Field{}
+	s.field.SetSeed(config.Seed)
 	s.field.New(size)
 }
 
-func (s *Mines) Attach(o Observers) {
-	s.subsribers = append(s.subsribers, o)
+func (e Event) Kind() Event {
+	return e
 }
 
-func (s *Mines) Dettach(o Observers) {
-	var idx int
-	for i, subscriber := range s.subsribers {
-		if subscriber == o {
-			idx = i
-			break
+/*
+.oPYo.
+ 8   `8
+o8YooP' o    o .oPYo.
+ 8   `b 8    8 Yb..
+ 8    8 8    8   'Yb.
+ 8oooP' `YooP' `YooP'
+:......::.....::.....:
+::::::::::::::::::::::
+::::::::::::::::::::::*/
+
+// Слой сверху всех прежних: рисуется последним, ввод получает первым
+func (s *Bus) Attach(o Observers) {
+	s.layers = append(s.layers, o)
+}
+
+func (s *Bus) Detach(o Observers) {
+	for i, layer := range s.layers {
+		if layer == o {
+			s.layers = append(s.layers[:i], s.layers[i+1:]...)
+			return
 		}
-		s.subsribers = append(s.subsribers[:idx], s.subsribers[idx+1:]...)
 	}
 }
 
-// Передать имеющиеся сообщения подписчикам
-func (s *Mines) Notify(e Event) {
-	for _, subscriber := range s.subsribers {
-		subscriber.Update(e)
+func (s *Bus) Layers() []Observers {
+	return s.layers
+}
+
+// Обработчик сообщений одного вида, вызывается раньше слоев
+func (s *Bus) Subscribe(kind Event, handler func(Message)) {
+	if s.handlers == nil {
+		s.handlers = map[Event][]func(Message){}
 	}
+	s.handlers[kind] = append(s.handlers[kind], handler)
+}
+
+// Сообщения встают в очередь до Dispatch
+func (s *Bus) Publish(messages ...Message) {
+	s.queue = append(s.queue, messages...)
 }
 
-func (s Mines) GetSubscribers() []Observers {
-	return s.subsribers
+// Раздать очередь по порядку: подписчикам вида, затем всем слоям.
+// Опубликованное по ходу раздачи раздается в этом же кадре
+func (s *Bus) Dispatch() {
+	for len(s.queue) > 0 {
+		msg := s.queue[0]
+		s.queue = s.queue[1:]
+		for _, handler := range s.handlers[msg.Kind()] {
+			handler(msg)
+		}
+		for _, layer := range s.layers {
+			layer.Update(msg)
+		}
+	}
 }
 
 /*
@@ -1615,18 +2328,24 @@ o     o  o
 ::::::::::::::::::::::::::::
 ::::::::::::::::::::::::::::*/
 func (s *View) Setup() (err error) {
-	if err = sdl.Init(sdl.INIT_EVERYTHING); err != nil {
-		panic(err)
+	// звук включается отдельно, без звукового устройства игра идет молча
+	if err = sdl.Init(sdl.INIT_VIDEO | sdl.INIT_TIMER | sdl.INIT_EVENTS); err != nil {
+		return fmt.Errorf("SDL: %w", err)
+	}
+	if err = ttf.Init(); err != nil {
+		return fmt.Errorf("SDL_ttf: %w", err)
 	}
-	err = ttf.Init()
+	// без шрифта все надписи пустые, об этом лучше сказать сразу
+	font, err := openFont(StatusLineFontSize)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	font.Close()
 	if s.window, err = sdl.CreateWindow("Mines", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, WinWidth, WinHeight, sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE); err != nil {
-		panic(err)
+		return fmt.Errorf("window: %w", err)
 	}
 	if s.renderer, err = sdl.CreateRenderer(s.window, -1, sdl.RENDERER_ACCELERATED); err != nil {
-		panic(err)
+		return fmt.Errorf("renderer: %w", err)
 	}
 	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "1")
 	s.pushTime = 10
@@ -1641,48 +2360,113 @@ func (s *View) Render(o []Observers) (err error) {
 	for _, subscriber := range o {
 		subscriber.Render(s.renderer)
 	}
+	if s.screenshot != "" {
+		if err := s.Screenshot(s.screenshot); err != nil {
+			logUI.Error("screenshot", "err", err)
+		} else {
+			logUI.Info("screenshot", "path", s.screenshot)
+		}
+		s.screenshot = ""
+	}
 	s.renderer.Present()
 	return nil
 }
 
-func (s *View) GetEvents(o []Observers) (events []Event) {
-	s.event = sdl.WaitEventTimeout(10)
+// Все события ввода за кадр, первое ждется не дольше 10 мс
+func (s *View) GetEvents(o []Observers) (events []Message) {
+	for s.event = sdl.WaitEventTimeout(10); s.event != nil; s.event = sdl.PollEvent() {
+		if s.input(&events) {
+			continue
+		}
+		// верхний слой получает события первым, модальное окно закрывает собой остальные
+		for i := len(o) - 1; i >= 0; i-- {
+			if msg := o[i].Event(s.event); msg != NilEvent {
+				events = append(events, msg)
+				break
+			}
+		}
+	}
+	if s.lastPushTime+s.pushTime < sdl.GetTicks() {
+		s.lastPushTime = sdl.GetTicks()
+		events = append(events, TickEvent)
+	}
+	return events
+}
+
+// Клавиши и окно, true если событие не должно идти слоям
+func (s *View) input(events *[]Message) bool {
 	switch t := s.event.(type) {
 	case *sdl.QuitEvent:
-		events = append(events, QuitEvent)
-		log.Printf("SEND Quit")
-		return events
+		*events = append(*events, QuitEvent)
+		logInput.Debug("window", "event", "quit")
+		return true
 	case *sdl.KeyboardEvent:
+		// на паузе клавиша только продолжает игру, ее действие под заставкой не выполняется
+		if s.paused {
+			if t.State == sdl.RELEASED {
+				*events = append(*events, AnyKeyEvent)
+				logInput.Debug("key", "sym", t.Keysym.Sym, "event", "resume")
+			}
+			return true
+		}
 		if t.Keysym.Sym == sdl.K_ESCAPE && t.State == sdl.RELEASED {
-			events = append(events, QuitEvent)
-			log.Printf("SEND Quit by escape")
-			return events
+			*events = append(*events, QuitEvent)
+			logInput.Debug("key", "key", "escape", "event", "quit")
+			return true
 		} else if t.Keysym.Sym == sdl.K_F11 && t.State == sdl.RELEASED {
-			events = append(events, FullScreenToggleEvent)
-			log.Printf("SEND window resize by F11")
-			return events
+			*events = append(*events, FullScreenToggleEvent)
+			logInput.Debug("key", "key", "F11", "event", "fullscreen")
+			return true
+		} else if t.Keysym.Sym == sdl.K_F12 && t.State == sdl.RELEASED {
+			*events = append(*events, ScreenshotEvent)
+			logInput.Debug("key", "key", "F12", "event", "screenshot")
+			return true
+		} else if t.Keysym.Sym == sdl.K_c && t.State == sdl.RELEASED {
+			*events = append(*events, NextPaletteEvent)
+			logInput.Debug("key", "key", "C", "event", "palette")
+			return true
+		} else if t.Keysym.Sym == sdl.K_F4 && t.State == sdl.RELEASED {
+			*events = append(*events, StatsToggleEvent)
+			logInput.Debug("key", "key", "F4", "event", "statistics")
+			return true
+		} else if t.Keysym.Sym == sdl.K_F5 && t.State == sdl.RELEASED {
+			*events = append(*events, SettingsToggleEvent)
+			logInput.Debug("key", "key", "F5", "event", "settings")
+			return true
+		} else if t.Keysym.Sym == sdl.K_d && t.State == sdl.RELEASED {
+			*events = append(*events, DailyEvent)
+			logInput.Debug("key", "key", "D", "event", "daily")
+			return true
+		} else if t.Keysym.Sym == sdl.K_p && t.State == sdl.RELEASED {
+			*events = append(*events, PuzzleToggleEvent)
+			logInput.Debug("key", "key", "P", "event", "puzzles")
+			return true
+		} else if t.Keysym.Sym == sdl.K_s && t.State == sdl.RELEASED {
+			*events = append(*events, EditorSaveEvent)
+			logInput.Debug("key", "key", "S", "event", "editor save")
+			return true
+		} else if t.Keysym.Sym == sdl.K_x && t.State == sdl.RELEASED {
+			*events = append(*events, ExportEvent)
+			logInput.Debug("key", "key", "X", "event", "export")
+			return true
+		} else if t.Keysym.Sym == sdl.K_g && t.State == sdl.RELEASED {
+			*events = append(*events, GlyphsToggleEvent)
+			logInput.Debug("key", "key", "G", "event", "glyphs")
+			return true
+		} else if t.State == sdl.RELEASED {
+			*events = append(*events, AnyKeyEvent)
 		}
 	case *sdl.WindowEvent:
 		if t.Event == sdl.WINDOWEVENT_RESIZED {
 			WinWidth, WinHeight = t.Data1, t.Data2
-			events = append(events, WindowResized)
-			log.Printf("SEND Window Resized")
+			*events = append(*events, WindowResized)
+			logInput.Debug("window", "event", "resized", "width", WinWidth, "height", WinHeight)
+		} else if t.Event == sdl.WINDOWEVENT_FOCUS_LOST || t.Event == sdl.WINDOWEVENT_MINIMIZED {
+			*events = append(*events, FocusLostEvent)
+			logInput.Debug("window", "event", "focus lost")
 		}
 	}
-
-	for _, subscriber := range o {
-		event := subscriber.Event(s.event)
-		if event != NilEvent {
-			events = append(events, event)
-			return events
-		}
-
-	}
-	if s.lastPushTime+s.pushTime < sdl.GetTicks() {
-		s.lastPushTime = sdl.GetTicks()
-		events = append(events, TickEvent)
-	}
-	return events
+	return false
 }
 
 /*
@@ -1695,113 +2479,159 @@ func (s *View) GetEvents(o []Observers) (events []Event) {
 :.....:8 ....::....::....::..:.....:..::::
 :::::::8 :::::::::::::::::::::::::::::::::
 :::::::..:::::::::::::::::::::::::::::::::*/
-func (s *Spinner) Run(m Mines, v View) {
-	defaultSize := boardConfig{row: row, column: column, mines: mines}
+func (s *Spinner) Run(m Mines, v View) error {
+	defaultSize := config.BoardSize()
 	rand.Seed(time.Now().UTC().UnixNano())
 	s.mines = m
 	s.mines.New(defaultSize)
 	if err := v.Setup(); err != nil {
-		panic(err)
+		return err
+	}
+	if config.Fullscreen {
+		v.flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+		v.window.SetFullscreen(v.flags)
 	}
 	statusLine := &StatusLine{}
 	statusLine.New(defaultSize)
-	s.mines.Attach(statusLine)
+	s.mines.bus.Attach(statusLine)
 	board := &GameBoard{}
 	board.New(defaultSize, true)
-	s.mines.Attach(board)
-	timer := Timer{}
-	timer.Reset()
-	timer.Start()
+	s.mines.bus.Attach(board)
+	animation := &Animation{}
+	animation.New(board)
+	s.mines.bus.Attach(animation)
+	stats := &Stats{}
+	if err := stats.Load(filepath.Join(dataDir(), "stats.json")); err != nil {
+		logEngine.Warn("stats load", "err", err)
+	}
+	statsBoard := &StatsBoard{}
+	statsBoard.New(stats)
+	s.mines.bus.Attach(statsBoard)
+	progress := &PuzzleProgress{}
+	if err := progress.Load(filepath.Join(dataDir(), "puzzles.json")); err != nil {
+		logEngine.Warn("puzzles load", "err", err)
+	}
+	puzzleSelect := &PuzzleSelect{}
+	puzzleSelect.New(loadPuzzles(), progress)
+	s.mines.bus.Attach(puzzleSelect)
+	settings := &Settings{}
+	settings.New()
+	s.mines.bus.Attach(settings)
+	sound := &Sound{}
+	sound.New()
+	defer sound.Destroy()
+	s.mines.bus.Attach(sound)
+	daily := &Leaderboard{}
+	if err := daily.Load(filepath.Join(dataDir(), "daily.json")); err != nil {
+		logEngine.Warn("daily load", "err", err)
+	}
+	game := NewGameController(&s.mines, stats, daily, progress)
+	if startDaily {
+		game.Daily(time.Now().Format(dailyDateFormat))
+	} else if boardFile != "" {
+		if layout, err := ImportLayout(boardFile); err != nil {
+			logEngine.Error("board import", "err", err)
+		} else {
+			// вопросы как в игре, из которой взят повтор
+			if layout.marksKnown {
+				config.QuestionMarks = layout.marks
+			}
+			game.StartPuzzle(layout.Puzzle(filepath.Base(boardFile)), "")
+		}
+	}
 	dirty := true
 	running := true
-	for running {
-		for _, event := range v.GetEvents(s.mines.GetSubscribers()) {
-			switch event {
-			case NewGameEvent:
-				s.mines.field.New(statusLine.gameBoardSize)
-				board.New(statusLine.gameBoardSize, true)
-				s.mines.field.SetState(gameStart)
-				timer.Reset()
-				timer.Start()
-			case ResetGameEvent:
-				s.mines.field.Reset()
-				board.New(statusLine.gameBoardSize, true)
-				timer.Reset()
-				timer.Start()
-			case PauseEvent:
-				if timer.IsPause() && s.mines.field.GetState() == gamePause {
-					timer.Start()
-					s.mines.field.SetState(gamePlay)
-				} else if s.mines.field.GetState() == gamePlay {
-					timer.Pause()
-					s.mines.field.SetState(gamePause)
-				}
-				board.SetBoard(s.mines.field.GetFieldValues(), s.mines.field.GetStatistic())
-			case MouseButtonLeftReleasedEvent:
-				if s.mines.field.GetState() == gameStart {
-					s.mines.field.Setup(board.mousePressedAtButton)
-					pos, cell := s.mines.field.getPosOfCell(board.mousePressedAtButton)
-					if cell.IsClosed() {
-						s.mines.field.Open(pos.X, pos.Y)
-					}
-					board.SetBoard(s.mines.field.GetFieldValues(), s.mines.field.GetStatistic())
-				} else if s.mines.field.GetState() == gamePlay {
-					pos, cell := s.mines.field.getPosOfCell(board.mousePressedAtButton)
-					if cell.IsClosed() {
-						s.mines.field.Open(pos.X, pos.Y)
-					} else if cell.IsOpened() {
-						s.mines.field.autoMarkFlags(pos.X, pos.Y)
-					}
-					if s.mines.field.isWin() || s.mines.field.isGameOver() {
-						timer.Stop()
-					}
-					board.SetBoard(s.mines.field.GetFieldValues(), s.mines.field.GetStatistic())
-				}
-			case MouseButtonRightReleasedEvent:
-				if s.mines.field.GetState() == gamePlay {
-					s.mines.field.MarkFlag(board.mousePressedAtButton)
-					board.SetBoard(s.mines.field.GetFieldValues(), s.mines.field.GetStatistic())
-				}
-				// board
-			case IncRowEvent: // Replace game board size by arrows
-				statusLine.gameBoardSize.row = int32(statusLine.btnInstances[4].(*Arrow).GetNumber()[0])
-			case DecRowEvent:
-				statusLine.gameBoardSize.row = int32(statusLine.btnInstances[4].(*Arrow).GetNumber()[0])
-			case IncColumnEvent:
-				statusLine.gameBoardSize.column = int32(statusLine.btnInstances[5].(*Arrow).GetNumber()[0])
-			case DecColumnEvent:
-				statusLine.gameBoardSize.column = int32(statusLine.btnInstances[5].(*Arrow).GetNumber()[0])
-			case IncMinesEvent:
-				statusLine.gameBoardSize.mines = int32(statusLine.btnInstances[6].(*Arrow).GetNumber()[0])
-			case DecMinesEvent:
-				statusLine.gameBoardSize.mines = int32(statusLine.btnInstances[6].(*Arrow).GetNumber()[0])
-			case FullScreenToggleEvent:
-				if v.flags == 0 {
-					v.flags = sdl.WINDOW_FULLSCREEN_DESKTOP
-				} else {
-					v.flags = 0
-				}
-				v.window.SetFullscreen(v.flags)
-				v.window.SetSize(WinWidth, WinHeight)
-				log.Printf("GOT screen toggle")
-			case WindowResized:
-				log.Printf("GOT Resized")
-			case QuitEvent:
-				running = false
-			case TickEvent:
-				dirty = true
-				timer.Update()
-				_, arr := timer.GetTimer()
-				board.SetTimer(arr)
-			}
-			s.mines.Notify(event)
+	bus := &s.mines.bus
+	bus.Subscribe(NewGameEvent, func(msg Message) {
+		size := msg.(BoardEvent).Size
+		config.SetBoardSize(size)
+		saveConfig()
+		game.NewGame(size)
+	})
+	bus.Subscribe(ResetGameEvent, func(Message) {
+		game.Reset()
+	})
+	bus.Subscribe(DailyEvent, func(Message) {
+		game.Daily(time.Now().Format(dailyDateFormat))
+	})
+	bus.Subscribe(EditorToggleEvent, func(msg Message) {
+		game.ToggleEditor(msg.(BoardEvent).Size)
+	})
+	bus.Subscribe(EditorSaveEvent, func(Message) {
+		game.SaveEditor()
+	})
+	bus.Subscribe(ExportEvent, func(Message) {
+		paths, err := game.Export(filepath.Join(dataDir(), "boards"))
+		if err != nil {
+			logEngine.Error("export", "err", err)
 		}
+		for _, path := range paths {
+			logEngine.Info("exported", "path", path)
+		}
+	})
+	bus.Subscribe(ScreenshotEvent, func(Message) {
+		// снимается следующий кадр
+		v.screenshot = screenshotPath()
+		dirty = true
+	})
+	bus.Subscribe(PuzzleSelectedEvent, func(msg Message) {
+		selected := msg.(PuzzleEvent)
+		game.StartPuzzle(selected.Puzzle, selected.Key)
+	})
+	bus.Subscribe(PauseEvent, func(Message) {
+		game.TogglePause()
+	})
+	bus.Subscribe(FocusLostEvent, func(Message) {
+		game.SetPause(true)
+	})
+	bus.Subscribe(AnyKeyEvent, func(Message) {
+		game.SetPause(false)
+	})
+	// каждая смена состояния публикует поле, по нему клавиатура знает о паузе
+	bus.Subscribe(FieldChangedEvent, func(Message) {
+		v.paused = game.State() == gamePause
+	})
+	bus.Subscribe(MouseButtonLeftReleasedEvent, func(msg Message) {
+		game.Click(msg.(CellEvent).Idx)
+	})
+	bus.Subscribe(ChordEvent, func(msg Message) {
+		game.Chord(msg.(CellEvent).Idx)
+	})
+	bus.Subscribe(MouseButtonRightReleasedEvent, func(msg Message) {
+		game.Flag(msg.(CellEvent).Idx)
+	})
+	bus.Subscribe(FullScreenToggleEvent, func(Message) {
+		if v.flags == 0 {
+			v.flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+		} else {
+			v.flags = 0
+		}
+		v.window.SetFullscreen(v.flags)
+		v.window.SetSize(WinWidth, WinHeight)
+		config.Fullscreen = v.flags != 0
+		saveConfig()
+		logUI.Debug("fullscreen", "on", config.Fullscreen)
+	})
+	bus.Subscribe(WindowResized, func(Message) {
+		logUI.Debug("resized", "width", WinWidth, "height", WinHeight)
+	})
+	bus.Subscribe(QuitEvent, func(Message) {
+		running = false
+	})
+	bus.Subscribe(TickEvent, func(Message) {
+		dirty = true
+		game.Tick()
+	})
+	for running {
+		bus.Publish(v.GetEvents(bus.Layers())...)
+		bus.Dispatch()
 		if dirty {
-			if err := v.Render(s.mines.GetSubscribers()); err != nil {
-				panic(err)
+			if err := v.Render(bus.Layers()); err != nil {
+				return err
 			}
 		}
 	}
+	return nil
 }
 
 /*
@@ -1815,8 +2645,43 @@ o     o         o
 ::::::::::::::::::::::::
 ::::::::::::::::::::::::*/
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "daily" {
+		if err := dailyCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		if err := convertCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		if err := renderCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := parseFlags(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
 	m := Mines{}
 	v := View{}
 	c := Spinner{}
-	c.Run(m, v)
+	if err := c.Run(m, v); err != nil {
+		showError(err)
+		os.Exit(1)
+	}
+}
+
+// Ошибка, с которой игра не может продолжаться: всегда в stderr, как у команд, и в окно сообщения, если SDL его покажет
+func showError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	if boxErr := sdl.ShowSimpleMessageBox(sdl.MESSAGEBOX_ERROR, "Mines", err.Error(), nil); boxErr != nil {
+		logUI.Warn("message box", "err", boxErr)
+	}
 }
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Поле по карте мин: строка на ряд, * мина, . пусто
+func mineMap(t testing.TB, rows ...string) *Field {
+	t.Helper()
+	layout, err := ParseMineMap(strings.NewReader(strings.Join(rows, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := &Field{}
+	field.SetLayout(layout)
+	return field
+}
+
+// Цифры должны совпадать с числом мин вокруг, у мин цифры нет
+func checkCounters(t testing.TB, field *Field) {
+	t.Helper()
+	for idx, cell := range field.field {
+		pos, _ := field.getPosOfCell(int32(idx))
+		var count int32
+		for _, n := range field.getNeighbours(pos.X, pos.Y) {
+			if n.GetMines() {
+				count++
+			}
+		}
+		if cell.GetMines() {
+			if cell.GetNumber() != -1 {
+				t.Fatalf("mine %v has counter %v", idx, cell.GetNumber())
+			}
+		} else if cell.GetNumber() != count {
+			t.Fatalf("cell %v counter %v, want %v", idx, cell.GetNumber(), count)
+		}
+	}
+}
+
+func countMines(field *Field) (mines int32) {
+	for _, cell := range field.field {
+		if cell.GetMines() {
+			mines++
+		}
+	}
+	return mines
+}
+
+func openedCells(field *Field) (cells []int32) {
+	for idx, cell := range field.field {
+		if cell.IsOpened() {
+			cells = append(cells, int32(idx))
+		}
+	}
+	return cells
+}
+
+func TestNeighboursAtEdges(t *testing.T) {
+	field := &Field{}
+	field.New(boardConfig{row: 5, column: 4, mines: 1})
+	tests := []struct {
+		x, y int32
+		want int
+	}{
+		{0, 0, 4}, {4, 0, 4}, {0, 3, 4}, {4, 3, 4},
+		{2, 0, 6}, {0, 1, 6}, {4, 2, 6}, {2, 3, 6},
+		{1, 1, 9}, {3, 2, 9},
+		{-1, 0, 2}, {5, 3, 2},
+	}
+	for _, tt := range tests {
+		if got := len(field.getNeighbours(tt.x, tt.y)); got != tt.want {
+			t.Errorf("neighbours of %v,%v: %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+	if idx, cell := field.getIdxOfCell(5, 0); idx != -1 || cell != nil {
+		t.Errorf("cell outside the board: %v %v", idx, cell)
+	}
+}
+
+func TestCountersMatchLayout(t *testing.T) {
+	field := mineMap(t,
+		"*...*",
+		".....",
+		"..*..",
+		"*...*")
+	checkCounters(t, field)
+	want := []int32{-1, 1, 0, 1, -1, 1, 2, 1, 2, 1, 1, 2, -1, 2, 1, -1, 2, 1, 2, -1}
+	for idx, cell := range field.field {
+		if cell.GetNumber() != want[idx] {
+			t.Errorf("cell %v: %v, want %v", idx, cell.GetNumber(), want[idx])
+		}
+	}
+	if got := field.boardSize.mines; got != 5 {
+		t.Errorf("mines %v, want 5", got)
+	}
+}
+
+func TestOpenCascade(t *testing.T) {
+	field := mineMap(t,
+		".....",
+		".....",
+		".....",
+		"....*")
+	field.Open(0, 0)
+	if got := len(openedCells(field)); got != 19 {
+		t.Errorf("opened %v cells, want 19", got)
+	}
+	if !field.field[19].IsClosed() {
+		t.Error("cascade opened the mine")
+	}
+	waves := field.TakeWaves()
+	if waves[0] != 0 || waves[6] != 1 || waves[18] != 3 || waves[14] != 4 {
+		t.Errorf("waves %v", waves)
+	}
+	if field.TakeWaves() != nil {
+		t.Error("waves are taken twice")
+	}
+}
+
+func TestOpenStopsAtNumbersAndFlags(t *testing.T) {
+	field := mineMap(t,
+		"..*..",
+		".....",
+		".....")
+	field.MarkFlag(11)
+	field.Open(4, 2)
+	if !field.field[11].IsFlagged() {
+		t.Error("cascade opened a flagged cell")
+	}
+	if !field.field[10].IsClosed() {
+		t.Error("cascade went through the flag")
+	}
+	if !field.field[6].IsOpened() || field.field[1].IsOpened() || field.field[2].IsOpened() {
+		t.Error("cascade did not stop at the numbers")
+	}
+	field.Open(-1, 0)
+	field.Open(0, 5)
+}
+
+func TestWin(t *testing.T) {
+	field := mineMap(t,
+		"*....",
+		".....",
+		"....*")
+	field.Open(1, 0)
+	if field.isWin() {
+		t.Fatal("win before the last cell")
+	}
+	field.Open(2, 1)
+	if !field.isWin() || field.GetState() != gameWin {
+		t.Fatalf("no win, state %v, opened %v", field.GetState(), len(openedCells(field)))
+	}
+	if !field.field[0].IsSavedMines() || !field.field[14].IsSavedMines() {
+		t.Error("mines are not marked saved after the win")
+	}
+	if field.isGameOver() {
+		t.Error("game over after the win")
+	}
+}
+
+func TestLoss(t *testing.T) {
+	field := mineMap(t,
+		"*.*..",
+		".....",
+		".....")
+	field.MarkFlag(2)
+	field.MarkFlag(4)
+	field.Open(0, 0)
+	if !field.isGameOver() || field.GetState() != gameOver {
+		t.Fatalf("no game over, state %v", field.GetState())
+	}
+	if field.isWin() {
+		t.Error("win after the loss")
+	}
+	if !field.field[0].IsFirstMines() {
+		t.Error("fatal mine is not marked")
+	}
+	if !field.field[2].IsSavedMines() {
+		t.Error("flagged mine is not saved")
+	}
+	if !field.field[4].IsWrongMines() {
+		t.Error("wrong flag is not marked")
+	}
+	if right, wrong := field.FlagsSummary(); right != 1 || wrong != 1 {
+		t.Errorf("flags right %v wrong %v, want 1 1", right, wrong)
+	}
+	if origin, mines := field.mineCells(); origin != 0 || len(mines) != 2 {
+		t.Errorf("mine cells %v %v", origin, mines)
+	}
+}
+
+func TestFlagCycle(t *testing.T) {
+	tests := []struct {
+		questionMarks bool
+		want          []int32
+	}{
+		{true, []int32{flagged, questionable, closed, flagged}},
+		{false, []int32{flagged, closed, flagged, closed}},
+	}
+	for _, tt := range tests {
+		field := mineMap(t, "*....")
+		field.SetQuestionMarks(tt.questionMarks)
+		for i, want := range tt.want {
+			field.MarkFlag(1)
+			if got := field.field[1].GetState(); got != want {
+				t.Errorf("questions %v step %v: state %v, want %v", tt.questionMarks, i, got, want)
+			}
+		}
+	}
+	field := mineMap(t, "*.*..")
+	field.Open(4, 0)
+	field.MarkFlag(4)
+	if !field.field[4].IsOpened() {
+		t.Error("flag on an opened cell")
+	}
+	field.MarkFlag(1)
+	field.Open(1, 0)
+	if field.field[1].IsOpened() {
+		t.Error("flagged cell opened")
+	}
+	for _, idx := range []int32{-1, 5, 100} {
+		field.MarkFlag(idx)
+	}
+}
+
+func TestMineCountOnPresets(t *testing.T) {
+	for name, size := range presets {
+		for _, mode := range []string{"off", "cell", "opening"} {
+			field := &Field{}
+			field.New(size)
+			field.SetSeed(42)
+			field.SetFirstClick(mode)
+			field.Setup(0)
+			if got := countMines(field); got != size.mines {
+				t.Errorf("%v %v: %v mines, want %v", name, mode, got, size.mines)
+			}
+			checkCounters(t, field)
+		}
+	}
+}
+
+func TestSetupWithTooManyMines(t *testing.T) {
+	field := &Field{}
+	field.New(boardConfig{row: 3, column: 3, mines: 20})
+	field.SetSeed(1)
+	field.SetFirstClick("cell")
+	field.Setup(4)
+	if got := countMines(field); got != 8 || field.field[4].GetMines() {
+		t.Errorf("%v mines, first cell mined %v", got, field.field[4].GetMines())
+	}
+}
+
+func TestSameSeedSameBoard(t *testing.T) {
+	layout := func() Layout {
+		field := &Field{}
+		field.New(presets["expert"])
+		field.SetSeed(7)
+		field.Setup(100)
+		return field.GetLayout()
+	}
+	a, b := layout(), layout()
+	for idx := range a.mines {
+		if a.mines[idx] != b.mines[idx] {
+			t.Fatalf("boards differ at %v", idx)
+		}
+	}
+}
+
+func TestDailyFallsBackToFirstSeed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := setupLogging("engine=warn", &buf); err != nil {
+		t.Fatal(err)
+	}
+	defer setupLogging("", os.Stderr)
+	defer func(check func(*Field, int32) bool) { dailyNoGuess = check }(dailyNoGuess)
+	tried := 0
+	dailyNoGuess = func(*Field, int32) bool { tried++; return false }
+	date := "2024-05-01"
+	field, start := dailyField(date)
+	if tried != dailyAttempts {
+		t.Errorf("%v seeds tried, want %v", tried, dailyAttempts)
+	}
+	first := &Field{}
+	first.New(presets["intermediate"])
+	first.SetSeed(dailySeed(date))
+	first.SetFirstClick("opening")
+	first.Setup(start)
+	got, want := field.GetLayout(), first.GetLayout()
+	for idx := range want.mines {
+		if got.mines[idx] != want.mines[idx] {
+			t.Fatalf("fallback board differs from the first seed at %v", idx)
+		}
+	}
+	if !strings.Contains(buf.String(), "daily board needs guessing") {
+		t.Errorf("no warning, log %q", buf.String())
+	}
+}
+
+// Все зерна перебираются решателем и не подходят: столько окно ждет задачу дня
+func BenchmarkDailyWorstCase(b *testing.B) {
+	defer func(check func(*Field, int32) bool) { dailyNoGuess = check }(dailyNoGuess)
+	dailyNoGuess = func(field *Field, start int32) bool {
+		field.NoGuess(start)
+		return false
+	}
+	for i := 0; i < b.N; i++ {
+		dailyField("2024-05-01")
+	}
+}
+
+// Размер и число мин из произвольных чисел, всегда допустимые
+func fuzzSize(row, column uint8, mines uint16) boardConfig {
+	size := boardConfig{row: int32(row)%(maxRow-minRow+1) + minRow, column: int32(column)%(maxColumn-minColumn+1) + minColumn}
+	size.mines = int32(mines)%(size.row*size.column-1) + 1
+	return size
+}
+
+func FuzzFirstClickIsSafe(f *testing.F) {
+	f.Add(int64(1), uint8(9), uint8(9), uint16(10), uint16(0), uint8(1))
+	f.Add(int64(99), uint8(30), uint8(16), uint16(99), uint16(479), uint8(2))
+	f.Add(int64(-5), uint8(5), uint8(5), uint16(24), uint16(12), uint8(2))
+	f.Fuzz(func(t *testing.T, seed int64, row, column uint8, mines, first uint16, mode uint8) {
+		if seed == 0 {
+			seed = 1
+		}
+		size := fuzzSize(row, column, mines)
+		firstIdx := int32(first) % (size.row * size.column)
+		field := &Field{}
+		field.New(size)
+		field.SetSeed(seed)
+		field.SetFirstClick([]string{"cell", "opening"}[mode%2])
+		field.Setup(firstIdx)
+		if field.field[firstIdx].GetMines() {
+			t.Fatalf("first click %v on a mine, size %v", firstIdx, size)
+		}
+		if got := countMines(field); got != size.mines {
+			t.Fatalf("%v mines, want %v", got, size.mines)
+		}
+		checkCounters(t, field)
+		pos, _ := field.getPosOfCell(firstIdx)
+		// с защитой соседей первый ход открывает область, если для мин хватает места
+		if neighbours := field.getNeighbours(pos.X, pos.Y); mode%2 == 1 && int32(len(field.field)-len(neighbours)) >= size.mines {
+			for _, cell := range neighbours {
+				if cell.GetMines() {
+					t.Fatalf("mine next to the first click %v, size %v", firstIdx, size)
+				}
+			}
+		}
+		field.Open(pos.X, pos.Y)
+		if field.GetState() == gameOver {
+			t.Fatal("first click lost the game")
+		}
+	})
+}
+
+func FuzzOpenTerminates(f *testing.F) {
+	f.Add(uint8(9), uint8(9), []byte{0, 10, 20}, uint16(40))
+	f.Add(uint8(30), uint8(16), []byte{}, uint16(0))
+	f.Add(uint8(5), uint8(5), []byte{1, 2, 3, 4, 5, 6, 7, 8, 9}, uint16(24))
+	f.Fuzz(func(t *testing.T, row, column uint8, mines []byte, start uint16) {
+		size := fuzzSize(row, column, 1)
+		layout := NewLayout(size)
+		for i, b := range mines {
+			layout.mines[(i*251+int(b))%len(layout.mines)] = true
+		}
+		field := &Field{}
+		field.SetLayout(layout)
+		checkCounters(t, field)
+		startIdx := int32(start) % (size.row * size.column)
+		pos, cell := field.getPosOfCell(startIdx)
+		mined := cell.GetMines()
+		field.Open(pos.X, pos.Y)
+		waves := field.TakeWaves()
+		if mined {
+			if field.GetState() != gameOver || len(waves) != 1 {
+				t.Fatalf("mine opened: state %v, waves %v", field.GetState(), waves)
+			}
+			return
+		}
+		cells := openedCells(field)
+		if len(cells) != len(waves) {
+			t.Fatalf("opened %v cells, %v in waves", len(cells), len(waves))
+		}
+		for _, idx := range cells {
+			if field.field[idx].GetMines() {
+				t.Fatalf("cascade opened mine %v", idx)
+			}
+		}
+		if field.isWin() != (int32(len(cells))+layout.Mines() == size.row*size.column) {
+			t.Fatalf("win %v with %v opened", field.GetState(), len(cells))
+		}
+	})
+}
+
+func TestArrowNumber(t *testing.T) {
+	tests := []struct {
+		label string
+		want  []int
+	}{
+		{"rows:9", []int{9, 0}},
+		{"mines:10:%:12", []int{10, 12}},
+		{"rows", nil},
+		{"rows:x", nil},
+		{"mines:10:%:", nil},
+	}
+	for _, tt := range tests {
+		got, err := (&Arrow{text: tt.label}).GetNumber()
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: no error, got %v", tt.label, got)
+			}
+		} else if err != nil || len(got) != 2 || got[0] != tt.want[0] || got[1] != tt.want[1] {
+			t.Errorf("%q: %v %v, want %v", tt.label, got, err, tt.want)
+		}
+	}
+}
+
+// Часы для секундомера, время идет только по advance
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time          { return f.t }
+func (f *fakeClock) advance(d time.Duration) { f.t = f.t.Add(d) }
+
+func TestTimer(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   func(s *Timer, clock *fakeClock)
+		elapsed time.Duration
+		started bool
+		precise string
+	}{
+		{"stands before the first click", func(s *Timer, clock *fakeClock) {
+			clock.advance(time.Minute)
+		}, 0, false, "00.000"},
+		{"starts on the first click", func(s *Timer, clock *fakeClock) {
+			clock.advance(time.Minute)
+			s.Start()
+			clock.advance(1500 * time.Millisecond)
+		}, 1500 * time.Millisecond, true, "01.500"},
+		{"second start keeps the time", func(s *Timer, clock *fakeClock) {
+			s.Start()
+			clock.advance(time.Second)
+			s.Start()
+			clock.advance(time.Second)
+		}, 2 * time.Second, true, "02.000"},
+		{"pause stops the time", func(s *Timer, clock *fakeClock) {
+			s.Start()
+			clock.advance(3 * time.Second)
+			s.Pause()
+			clock.advance(time.Hour)
+		}, 3 * time.Second, true, "03.000"},
+		{"resume adds up", func(s *Timer, clock *fakeClock) {
+			s.Start()
+			clock.advance(1200 * time.Millisecond)
+			s.Pause()
+			clock.advance(time.Hour)
+			s.Resume()
+			clock.advance(345 * time.Millisecond)
+		}, 1545 * time.Millisecond, true, "01.545"},
+		{"resume before the first click", func(s *Timer, clock *fakeClock) {
+			s.Resume()
+			clock.advance(time.Second)
+		}, 0, false, "00.000"},
+		{"stop and reset", func(s *Timer, clock *fakeClock) {
+			s.Start()
+			clock.advance(time.Second)
+			s.Stop()
+			s.Reset()
+			clock.advance(time.Second)
+		}, 0, false, "00.000"},
+		{"precise cuts to milliseconds", func(s *Timer, clock *fakeClock) {
+			s.Start()
+			clock.advance(42*time.Second + 7*time.Millisecond + 999*time.Microsecond)
+		}, 42*time.Second + 7*time.Millisecond + 999*time.Microsecond, true, "42.007"},
+	}
+	for _, tt := range tests {
+		clock := &fakeClock{t: time.Unix(1e9, 0)}
+		s := &Timer{clock: clock.now}
+		s.Reset()
+		tt.steps(s, clock)
+		if got := s.Elapsed(); got != tt.elapsed {
+			t.Errorf("%v: elapsed %v, want %v", tt.name, got, tt.elapsed)
+		}
+		if s.started != tt.started {
+			t.Errorf("%v: started %v", tt.name, s.started)
+		}
+		if got := s.Precise(); got != tt.precise {
+			t.Errorf("%v: precise %q, want %q", tt.name, got, tt.precise)
+		}
+	}
+}
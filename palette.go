@@ -0,0 +1,115 @@
+package main
+
+import "github.com/veandco/go-sdl2/sdl"
+
+/*
+.oPYo.         8        o   o
+ 8    8        8         8   8
+o8YooP' .oPYo. 8 .oPYo. o8P o8P .oPYo.
+ 8      .oooo8 8 8oooo8  8   8  8oooo8
+ 8      8    8 8 8.      8   8  8.
+ 8      `YooP8 8 `Yooo'  8   8  `Yooo'
+:......::.....:..:.....::..::..::.....:
+:::::::::::::::::::::::::::::::::::::::
+:::::::::::::::::::::::::::::::::::::::*/
+
+type (
+	// Палитра поля: 0 фон открытой ячейки, 1-8 цвета цифр, 7 цвет текста, 8 фон закрытой ячейки
+	Palette struct {
+		name   string
+		colors []sdl.Color
+	}
+)
+
+// Классическая палитра на случай, если ни одна тема не загрузилась. Темы лежат в assets/themes
+var defaultPalette = Palette{name: "classic", colors: []sdl.Color{{192, 192, 192, 255}, {0, 0, 255, 255}, {0, 128, 0, 255}, {255, 0, 0, 255}, {0, 0, 128, 255}, {128, 0, 0, 255}, {0, 128, 128, 255}, {0, 0, 0, 255}, {128, 128, 128, 255}}}
+
+// Загруженные темы, читаются при первом обращении
+var palettes []Palette
+
+func allPalettes() []Palette {
+	if palettes == nil {
+		palettes = loadPalettes()
+	}
+	return palettes
+}
+
+// Цвета выбранной палитры, неизвестное имя дает первую по алфавиту
+func getPalette(name string) []sdl.Color {
+	for _, p := range allPalettes() {
+		if p.name == name {
+			return p.colors
+		}
+	}
+	return allPalettes()[0].colors
+}
+
+// Следующая палитра по кругу
+func nextPalette(name string) string {
+	list := allPalettes()
+	for i, p := range list {
+		if p.name == name {
+			return list[(i+1)%len(list)].name
+		}
+	}
+	return list[0].name
+}
+
+// Расположение точек как на игральной кости для цифр 1-8 в сетке 3x3
+var pips = [][]sdl.Point{
+	{},
+	{{1, 1}},
+	{{0, 0}, {2, 2}},
+	{{0, 0}, {1, 1}, {2, 2}},
+	{{0, 0}, {2, 0}, {0, 2}, {2, 2}},
+	{{0, 0}, {2, 0}, {1, 1}, {0, 2}, {2, 2}},
+	{{0, 0}, {2, 0}, {0, 1}, {2, 1}, {0, 2}, {2, 2}},
+	{{0, 0}, {2, 0}, {0, 1}, {1, 1}, {2, 1}, {0, 2}, {2, 2}},
+	{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {2, 1}, {0, 2}, {1, 2}, {2, 2}},
+}
+
+// Рисует в правом нижнем углу ячейки фигуру, отличающую цифру или состояние без опоры на цвет
+func drawGlyph(renderer *sdl.Renderer, rect *sdl.Rect, value int32, fg sdl.Color) {
+	size := rect.H / 3
+	if rect.W < rect.H {
+		size = rect.W / 3
+	}
+	if size < 6 {
+		return
+	}
+	x, y := rect.X+rect.W-size-2, rect.Y+rect.H-size-2
+	renderer.SetDrawColor(fg.R, fg.G, fg.B, fg.A)
+	switch value {
+	case 1, 2, 3, 4, 5, 6, 7, 8:
+		step := size / 3
+		for _, p := range pips[value] {
+			renderer.FillRect(&sdl.Rect{x + p.X*step + 1, y + p.Y*step + 1, step - 1, step - 1})
+		}
+	case flagged: // вымпел на древке
+		renderer.DrawLine(x+1, y, x+1, y+size)
+		for i := int32(0); i < size/2; i++ {
+			renderer.DrawLine(x+1, y+i, x+size-i*2, y+i)
+		}
+	case questionable: // пустой ромб
+		h := size / 2
+		renderer.DrawLine(x+h, y, x+size, y+h)
+		renderer.DrawLine(x+size, y+h, x+h, y+size)
+		renderer.DrawLine(x+h, y+size, x, y+h)
+		renderer.DrawLine(x, y+h, x+h, y)
+	case saved: // галочка
+		renderer.DrawLine(x, y+size/2, x+size/3, y+size)
+		renderer.DrawLine(x+size/3, y+size, x+size, y)
+	case blown: // крест
+		renderer.DrawLine(x, y, x+size, y+size)
+		renderer.DrawLine(x+size, y, x, y+size)
+	case mined: // закрашенный квадрат
+		renderer.FillRect(&sdl.Rect{x + size/4, y + size/4, size / 2, size / 2})
+	case firstMined: // квадрат в рамке
+		renderer.FillRect(&sdl.Rect{x + size/4, y + size/4, size / 2, size / 2})
+		renderer.DrawRect(&sdl.Rect{x, y, size, size})
+	case wrongMines: // перечеркнутая мина
+		renderer.FillRect(&sdl.Rect{x + size/4, y + size/4, size / 2, size / 2})
+		renderer.DrawLine(x, y, x+size, y+size)
+		renderer.DrawLine(x+size, y, x, y+size)
+	}
+}
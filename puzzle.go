@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+.oPYo.                     8
+ 8    8                    8
+o8YooP' o    o ooooo ooooo 8 .oPYo.
+ 8      8    8   .d'   .d' 8 8oooo8
+ 8      8    8 .d'   .d'   8 8.
+ 8      `YooP' 8oooo 8oooo 8 `Yooo'
+:......::.....::....::....:..:.....:
+::::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::::*/
+
+// Формат набора головоломок, текстовый файл:
+//
+//	# комментарий
+//	pack Название набора
+//	puzzle Название головоломки
+//	oooo.
+//	o!?.*
+//
+// Строки поля идут сразу за puzzle до пустой строки. Знаки ячеек:
+// . закрытая пустая, * закрытая мина, o открытая, ? цель пустая, ! цель мина.
+// Без целей головоломка решена, когда открыты все пустые ячейки
+type (
+	Puzzle struct {
+		Name        string
+		Row, Column int32
+		Cells       []byte
+	}
+	PuzzlePack struct {
+		Name    string
+		Puzzles []*Puzzle
+	}
+	// Решенные головоломки по ключу набор/название
+	PuzzleProgress struct {
+		path   string
+		Solved map[string]bool `json:"solved"`
+	}
+)
+
+const (
+	puzzleSafe       = '.'
+	puzzleMine       = '*'
+	puzzleOpen       = 'o'
+	puzzleTargetSafe = '?'
+	puzzleTargetMine = '!'
+)
+
+func ParsePuzzlePack(r io.Reader, name string) (*PuzzlePack, error) {
+	pack := &PuzzlePack{Name: name}
+	var current *Puzzle
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case strings.HasPrefix(text, "#"):
+		case text == "":
+			if current != nil && len(current.Cells) > 0 {
+				current = nil
+			}
+		case strings.HasPrefix(text, "pack "):
+			pack.Name = strings.TrimSpace(text[len("pack "):])
+		case strings.HasPrefix(text, "puzzle "):
+			current = &Puzzle{Name: strings.TrimSpace(text[len("puzzle "):])}
+			pack.Puzzles = append(pack.Puzzles, current)
+		default:
+			if current == nil {
+				return nil, fmt.Errorf("line %v: board row outside a puzzle", line)
+			}
+			if strings.Trim(text, ".*o?!") != "" {
+				return nil, fmt.Errorf("line %v: unknown cell in %q", line, text)
+			}
+			if current.Row == 0 {
+				current.Row = int32(len(text))
+			} else if current.Row != int32(len(text)) {
+				return nil, fmt.Errorf("line %v: row length %v, want %v", line, len(text), current.Row)
+			}
+			current.Cells = append(current.Cells, text...)
+			current.Column++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, p := range pack.Puzzles {
+		if len(p.Cells) == 0 {
+			return nil, fmt.Errorf("puzzle %q has no board", p.Name)
+		}
+	}
+	return pack, nil
+}
+
+// Записать набор в том же формате, что читает ParsePuzzlePack
+func (pack *PuzzlePack) Format(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "pack %v\n", pack.Name); err != nil {
+		return err
+	}
+	for _, p := range pack.Puzzles {
+		if _, err := fmt.Fprintf(w, "\npuzzle %v\n", p.Name); err != nil {
+			return err
+		}
+		for y := int32(0); y < p.Column; y++ {
+			if _, err := fmt.Fprintf(w, "%s\n", p.Cells[y*p.Row:(y+1)*p.Row]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Свои головоломки и поля из редактора: $XDG_DATA_HOME/mines/puzzles
+func userPuzzlesDir() string {
+	return filepath.Join(dataDir(), "puzzles")
+}
+
+// Наборы из ресурсов, затем свои из редактора
+func loadPuzzles() []*PuzzlePack {
+	bundled, _ := fs.Sub(assets, "puzzles")
+	return append(LoadPuzzlePacks(bundled), LoadPuzzlePacks(os.DirFS(userPuzzlesDir()))...)
+}
+
+// Наборы *.txt, головоломки без единственного логического решения пропускаются
+func LoadPuzzlePacks(fsys fs.FS) (packs []*PuzzlePack) {
+	names, _ := fs.Glob(fsys, "*.txt")
+	sort.Strings(names)
+	for _, name := range names {
+		file, err := fsys.Open(name)
+		if err != nil {
+			logEngine.Warn("puzzles", "err", err)
+			continue
+		}
+		pack, err := ParsePuzzlePack(file, strings.TrimSuffix(name, ".txt"))
+		file.Close()
+		if err != nil {
+			logEngine.Warn("puzzles", "pack", name, "err", err)
+			continue
+		}
+		var valid []*Puzzle
+		for _, p := range pack.Puzzles {
+			if err := p.Validate(); err != nil {
+				logEngine.Warn("puzzle", "pack", pack.Name, "puzzle", p.Name, "err", err)
+				continue
+			}
+			valid = append(valid, p)
+		}
+		if pack.Puzzles = valid; len(valid) > 0 {
+			packs = append(packs, pack)
+		}
+	}
+	return packs
+}
+
+func (p *Puzzle) Mines() (mines int32) {
+	for _, c := range p.Cells {
+		if c == puzzleMine || c == puzzleTargetMine {
+			mines++
+		}
+	}
+	return mines
+}
+
+// Цели головоломки, nil если целей нет и нужно открыть все поле
+func (p *Puzzle) Targets() (targets map[int32]bool) {
+	for idx, c := range p.Cells {
+		if c != puzzleTargetSafe && c != puzzleTargetMine {
+			continue
+		}
+		if targets == nil {
+			targets = map[int32]bool{}
+		}
+		targets[int32(idx)] = true
+	}
+	return targets
+}
+
+// Поле головоломки: мины расставлены, открытые ячейки показывают цифры, игра идет
+func (p *Puzzle) Field() (field Field) {
+	field.New(boardConfig{row: p.Row, column: p.Column, mines: p.Mines()})
+	for idx, c := range p.Cells {
+		if c == puzzleMine || c == puzzleTargetMine {
+			field.field[idx].SetMines()
+		}
+	}
+	field.setNumbers()
+	for idx, c := range p.Cells {
+		if c == puzzleOpen {
+			field.field[idx].Open()
+		}
+	}
+	field.SetTargets(p.Targets())
+	field.SetState(gamePlay)
+	return field
+}
+
+// Решателю не с чего начать: на поле нет ни одной открытой ячейки
+var errNoOpened = errors.New("no opened cells")
+
+// Каждая цель выводится решателем, открывая по пути только доказанно пустые ячейки.
+// Вывод верен для любой расстановки, согласной с цифрами, значит решение единственное.
+// Без целей выводиться должны все закрытые ячейки
+func (p *Puzzle) Validate() error {
+	field := p.Field()
+	if field.openedCount() == 0 {
+		return errNoOpened
+	}
+	targets := p.Targets()
+	if targets == nil {
+		targets = map[int32]bool{}
+		for idx, c := range p.Cells {
+			if c != puzzleOpen {
+				targets[int32(idx)] = true
+			}
+		}
+	}
+	for {
+		safe, mines := NewSolver(p.Row, p.Column, p.Mines(), field.solverView()).Deduce()
+		known := map[int32]bool{}
+		for _, idx := range append(safe, mines...) {
+			known[idx] = true
+		}
+		left := 0
+		for idx := range targets {
+			if !known[idx] {
+				left++
+			}
+		}
+		if left == 0 {
+			return nil
+		}
+		opened := field.openedCount()
+		for _, idx := range safe {
+			pos, _ := field.getPosOfCell(idx)
+			field.Open(pos.X, pos.Y)
+		}
+		if field.openedCount() == opened {
+			return fmt.Errorf("%v target cells need a guess", left)
+		}
+	}
+}
+
+func (s *PuzzleProgress) Load(path string) error {
+	s.path = path
+	s.Solved = map[string]bool{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, s)
+}
+
+func (s *PuzzleProgress) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+func (s *PuzzleProgress) SetSolved(key string) {
+	s.Solved[key] = true
+	if err := s.Save(); err != nil {
+		logEngine.Error("puzzles save", "err", err)
+	}
+}
+
+/*
+.oPYo.                     8        .oPYo.        8               o
+ 8    8                    8        8             8                8
+o8YooP' o    o ooooo ooooo 8 .oPYo. `Yooo. .oPYo. 8 .oPYo. .oPYo. o8P
+ 8      8    8   .d'   .d' 8 8oooo8     `8 8oooo8 8 8oooo8 8    '  8
+ 8      8    8 .d'   .d'   8 8.          8 8.     8 8.     8    .  8
+ 8      `YooP' 8oooo 8oooo 8 `Yooo' `YooP' `Yooo' 8 `Yooo' `YooP'  8
+:......::.....::....::....:..:.....::.....::.....:..:.....::.....::..:
+::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::*/
+
+type (
+	// Наблюдатель окно выбора головоломки, стрелка листает наборы
+	PuzzleSelect struct {
+		rect         sdl.Rect
+		packs        []*PuzzlePack
+		progress     *PuzzleProgress
+		pack         int
+		buttons      []*Button
+		btnInstances []interface{}
+		visible      bool
+	}
+)
+
+func puzzleKey(pack *PuzzlePack, p *Puzzle) string {
+	return pack.Name + "/" + p.Name
+}
+
+func (s *PuzzleSelect) New(packs []*PuzzlePack, progress *PuzzleProgress) {
+	s.packs = packs
+	s.progress = progress
+	s.Setup()
+}
+
+func (s *PuzzleSelect) Setup() {
+	if len(s.btnInstances) > 0 {
+		s.Destroy()
+		s.btnInstances, s.buttons = nil, nil
+	}
+	h := StatusLineHeight
+	var puzzles []*Puzzle
+	packName := ""
+	if len(s.packs) > 0 {
+		puzzles, packName = s.packs[s.pack].Puzzles, s.packs[s.pack].Name
+	}
+	w, ht := WinWidth*2/3, h*int32(len(puzzles)+4)
+	s.rect = sdl.Rect{(WinWidth - w) / 2, (WinHeight - ht) / 2, w, ht}
+	title := &Label{}
+	title.Setup(sdl.Point{s.rect.X + h, s.rect.Y + 2}, tr("puzzles"), StatusLineFontSize, ForegroundStatusLine)
+	s.btnInstances = append(s.btnInstances, title)
+	arrow := &Arrow{}
+	arrow.New(sdl.Rect{s.rect.X + h, s.rect.Y + h, w - h*2, h}, tr("puzzle_pack")+":"+packName, BackgroundStatusLine, ForegroundStatusLine, StatusLineFontSize)
+	s.btnInstances = append(s.btnInstances, arrow)
+	for i, p := range puzzles {
+		mark := "[ ] "
+		if s.progress.Solved[puzzleKey(s.packs[s.pack], p)] {
+			mark = "[x] "
+		}
+		btn := &Button{}
+		btn.Setup(sdl.Rect{h, h * int32(i+2), w - h*2, h}, sdl.Point{s.rect.X, s.rect.Y}, mark+p.Name, StatusLineFontSize, BackgroundStatusLine, ForegroundStatusLine)
+		s.buttons = append(s.buttons, btn)
+		s.btnInstances = append(s.btnInstances, btn)
+	}
+	btn := &Button{}
+	btn.Setup(sdl.Rect{(s.rect.W - h*4) / 2, s.rect.H - h - h/2, h * 4, h}, sdl.Point{s.rect.X, s.rect.Y}, tr("ok"), StatusLineFontSize, BackgroundStatusLine, ForegroundStatusLine)
+	s.btnInstances = append(s.btnInstances, btn)
+}
+
+func (s *PuzzleSelect) SetPacks(packs []*PuzzlePack) {
+	s.packs = packs
+	if s.pack >= len(packs) {
+		s.pack = 0
+	}
+	s.Setup()
+}
+
+func (s *PuzzleSelect) Update(event Message) {
+	switch event.Kind() {
+	case PuzzleToggleEvent:
+		if s.visible = !s.visible; s.visible {
+			s.Setup()
+		}
+	case SettingsChangedEvent, WindowResized, GameEndEvent:
+		s.Setup()
+	case PuzzlesChangedEvent:
+		s.SetPacks(loadPuzzles())
+	}
+	if !s.visible {
+		return
+	}
+	for _, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			button.(*Button).Update()
+		case *Arrow:
+			button.(*Arrow).Update(event.Kind())
+		}
+	}
+}
+
+func (s *PuzzleSelect) Render(renderer *sdl.Renderer) {
+	if !s.visible {
+		return
+	}
+	renderer.SetDrawColor(BackgroundStatusLine.R, BackgroundStatusLine.G, BackgroundStatusLine.B, BackgroundStatusLine.A)
+	renderer.FillRect(&s.rect)
+	renderer.SetDrawColor(ForegroundStatusLine.R, ForegroundStatusLine.G, ForegroundStatusLine.B, ForegroundStatusLine.A)
+	renderer.DrawRect(&s.rect)
+	for _, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			button.(*Button).Render(renderer)
+		case *Arrow:
+			button.(*Arrow).Render(renderer)
+		case *Label:
+			button.(*Label).Render(renderer)
+		}
+	}
+}
+
+func (s *PuzzleSelect) Event(event sdl.Event) (e Message) {
+	if !s.visible {
+		return NilEvent
+	}
+	switch event.(type) {
+	case *sdl.MouseButtonEvent:
+		for i, btn := range s.buttons {
+			if ok := btn.Event(event); ok == MouseButtonLeftReleasedEvent {
+				s.visible = false
+				pack := s.packs[s.pack]
+				return PuzzleEvent{PuzzleSelectedEvent, pack.Puzzles[i], puzzleKey(pack, pack.Puzzles[i])}
+			}
+		}
+		if ok := s.btnInstances[len(s.btnInstances)-1].(*Button).Event(event); ok == MouseButtonLeftReleasedEvent {
+			return PuzzleToggleEvent
+		}
+		if len(s.packs) > 0 {
+			switch s.btnInstances[1].(*Arrow).Event(event) {
+			case IncButtonEvent:
+				s.pack = (s.pack + 1) % len(s.packs)
+				s.Setup()
+			case DecButtonEvent:
+				s.pack = (s.pack - 1 + len(s.packs)) % len(s.packs)
+				s.Setup()
+			}
+		}
+		return ConsumedEvent
+	}
+	return NilEvent
+}
+
+func (s *PuzzleSelect) Destroy() {
+	for _, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			button.(*Button).Destroy()
+		case *Arrow:
+			button.(*Arrow).Destroy()
+		case *Label:
+			button.(*Label).Destroy()
+		}
+	}
+}
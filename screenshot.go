@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+/*
+.oPYo.   o        o
+ 8    8            8
+o8YooP' o8 .oPYo. o8P o    o oPYo. .oPYo.
+ 8       8 8    '  8  8    8 8  `' 8oooo8
+ 8       8 8    .  8  8    8 8     8.
+ 8       8 `YooP'  8  `YooP' 8     `Yooo'
+:......::..:.....::..::.....:..:::::.....:
+::::::::::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::::::::::*/
+
+// Сторона картинки поля по умолчанию для mines render
+const renderSize = 600
+
+// Строки пикселей RGBA с произвольным шагом в картинку, прозрачность не сохраняется
+func pixelsImage(pixels []byte, w, h, pitch int32) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	for y := int32(0); y < h; y++ {
+		row := img.Pix[int(y)*img.Stride : int(y)*img.Stride+int(w)*4]
+		copy(row, pixels[y*pitch:y*pitch+w*4])
+		for x := 3; x < len(row); x += 4 {
+			row[x] = 255
+		}
+	}
+	return img
+}
+
+func savePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Снимок окна читается из отрисованного кадра до Present, после него буфер не определен
+func (s *View) Screenshot(path string) error {
+	w, h, err := s.renderer.GetOutputSize()
+	if err != nil {
+		return err
+	}
+	pixels := make([]byte, w*h*4)
+	if err = s.renderer.ReadPixels(nil, sdl.PIXELFORMAT_ABGR8888, unsafe.Pointer(&pixels[0]), int(w*4)); err != nil {
+		return err
+	}
+	return savePNG(path, pixelsImage(pixels, w, h, w*4))
+}
+
+// Снимки окна по F12
+func screenshotPath() string {
+	return filepath.Join(dataDir(), "screenshots", time.Now().Format("20060102-150405.000")+".png")
+}
+
+// Программный рендерер, рисующий в поверхность в памяти вместо окна
+func offscreen(w, h int32) (*sdl.Surface, *sdl.Renderer, error) {
+	surface, err := sdl.CreateRGBSurfaceWithFormat(0, w, h, 32, sdl.PIXELFORMAT_ABGR8888)
+	if err != nil {
+		return nil, nil, err
+	}
+	renderer, err := sdl.CreateSoftwareRenderer(surface)
+	if err != nil {
+		surface.Free()
+		return nil, nil, err
+	}
+	return surface, renderer, nil
+}
+
+func surfaceImage(surface *sdl.Surface) *image.RGBA {
+	return pixelsImage(surface.Pixels(), surface.W, surface.H, surface.Pitch)
+}
+
+// Поле головоломки без окна. Мины видны, открытые ячейки с цифрами, под полем подписи left и right
+func renderPuzzleImage(p *Puzzle, size int32, left, right string) (*image.RGBA, error) {
+	surface, renderer, err := offscreen(size, size)
+	if err != nil {
+		return nil, err
+	}
+	defer surface.Free()
+	defer renderer.Destroy()
+	// поле раскладывается по размерам окна, окном здесь служит картинка
+	WinWidth, WinHeight = size, size
+	StatusLineHeight = WinHeight / 20
+	StatusLineFontSize = int(StatusLineHeight) - 3
+	editor := &Editor{puzzle: p}
+	board := &GameBoard{}
+	board.New(boardConfig{row: p.Row, column: p.Column, mines: p.Mines()}, true)
+	defer board.Destroy()
+	board.SetBoard(editor.Values(), []int{int(p.Mines()), 0, 0})
+	board.SetTargets(editor.Targets())
+	board.SetInfo(left, right)
+	renderer.SetDrawColor(Background.R, Background.G, Background.B, Background.A)
+	renderer.Clear()
+	board.Render(renderer)
+	return surfaceImage(surface), nil
+}
+
+// Расстановка из файла или головоломка из набора по номеру с единицы
+func loadBoardPuzzle(path string, index int) (*Puzzle, error) {
+	layout, err := ImportLayout(path)
+	if err == nil {
+		return layout.Puzzle(filepath.Base(path)), nil
+	}
+	f, ferr := os.Open(path)
+	if ferr != nil {
+		return nil, ferr
+	}
+	defer f.Close()
+	pack, perr := ParsePuzzlePack(f, filepath.Base(path))
+	if perr != nil {
+		// не набор головоломок, значит ошибка в расстановке
+		return nil, err
+	}
+	if index < 1 || index > len(pack.Puzzles) {
+		return nil, fmt.Errorf("puzzle %v of %v", index, len(pack.Puzzles))
+	}
+	return pack.Puzzles[index-1], nil
+}
+
+// mines render [-open] [-size N] [-puzzle N] [-config file] in out.png: картинка поля для отчетов об ошибках,
+// тема и язык из настроек, испорченный файл настроек как в игре заменяется значениями по умолчанию
+func renderCommand(args []string) error {
+	fset := flag.NewFlagSet("render", flag.ContinueOnError)
+	open := fset.Bool("open", false, "open every safe cell of a layout")
+	size := fset.Int("size", renderSize, "image side in pixels")
+	index := fset.Int("puzzle", 1, "puzzle number in a puzzle pack")
+	configPath := fset.String("config", defaultConfigPath(), "config file")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 2 {
+		return errors.New("usage: mines render [-open] [-size N] [-puzzle N] [-config file] in.{avf,rawvf,txt} out.png")
+	}
+	p, err := loadBoardPuzzle(fset.Arg(0), *index)
+	if err != nil {
+		return fmt.Errorf("%v: %w", fset.Arg(0), err)
+	}
+	if *open {
+		for idx, c := range p.Cells {
+			if c == puzzleSafe {
+				p.Cells[idx] = puzzleOpen
+			}
+		}
+	}
+	if config, err = loadConfig(*configPath); err != nil {
+		logUI.Warn("config load", "err", err)
+	}
+	applyConfig()
+	if err = ttf.Init(); err != nil {
+		return err
+	}
+	defer ttf.Quit()
+	field := p.Field()
+	bbbv, _ := field.Get3BV()
+	img, err := renderPuzzleImage(p, int32(*size), p.Name, fmt.Sprintf(tr("editor_3bv"), bbbv))
+	if err != nil {
+		return err
+	}
+	return savePNG(fset.Arg(1), img)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+.oPYo.        o   o    o
+8              8   8
+`Yooo. .oPYo. o8P o8P o8 odYo. .oPYo. .oPYo.
+    `8 8oooo8  8   8   8 8' `8 8    8 Yb..
+     8 8.      8   8   8 8   8 8    8   'Yb.
+`YooP' `Yooo'  8   8   8 8   8 `YooP8 `YooP'
+:.....::.....::..::..::....::..:....8 :.....:
+:::::::::::::::::::::::::::::::::ooP'.:::::::
+:::::::::::::::::::::::::::::::::...:::::::::*/
+
+type (
+	// Строка окна настроек, значения перебираются стрелками по кругу
+	settingsOption struct {
+		key    string
+		values []string
+		get    func() string
+		set    func(string)
+	}
+	// Наблюдатель окно настроек, модальное, изменения применяются сразу
+	Settings struct {
+		rect         sdl.Rect
+		options      []settingsOption
+		btnInstances []interface{}
+		visible      bool
+	}
+)
+
+func onOff(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
+
+func (s *Settings) New() {
+	var themes []string
+	for _, p := range allPalettes() {
+		themes = append(themes, p.name)
+	}
+	var volumes []string
+	for v := 0; v <= 100; v += 10 {
+		volumes = append(volumes, strconv.Itoa(v))
+	}
+	s.options = []settingsOption{
+		{key: "question_marks", values: []string{"on", "off"},
+			get: func() string { return onOff(config.QuestionMarks) },
+			set: func(v string) { config.QuestionMarks = v == "on" }},
+		{key: "first_click", values: []string{"cell", "opening", "off"},
+			get: func() string { return config.FirstClick },
+			set: func(v string) { config.FirstClick = v }},
+		{key: "chord", values: []string{"left", "both", "off"},
+			get: func() string { return config.Chord },
+			set: func(v string) { config.Chord = v }},
+		{key: "auto_flag", values: []string{"on", "off"},
+			get: func() string { return onOff(config.AutoFlag) },
+			set: func(v string) { config.AutoFlag = v == "on" }},
+		{key: "theme", values: themes,
+			get: func() string { return config.Theme },
+			set: func(v string) { config.Theme = v }},
+		{key: "glyphs", values: []string{"on", "off"},
+			get: func() string { return onOff(config.Glyphs) },
+			set: func(v string) { config.Glyphs = v == "on" }},
+		{key: "language", values: []string{"auto", "en", "ru"},
+			get: func() string {
+				if config.Language == "" {
+					return "auto"
+				}
+				return config.Language
+			},
+			set: func(v string) {
+				if config.Language = v; v == "auto" {
+					config.Language = ""
+				}
+				applyLocale()
+			}},
+		{key: "sound", values: []string{"on", "off"},
+			get: func() string { return onOff(config.Sound) },
+			set: func(v string) { config.Sound = v == "on" }},
+		{key: "volume", values: volumes,
+			get: func() string { return strconv.Itoa(config.Volume) },
+			set: func(v string) { config.Volume, _ = strconv.Atoi(v) }},
+		{key: "animation", values: []string{"off", "slow", "normal", "fast"},
+			get: func() string { return config.Animation },
+			set: func(v string) { config.Animation = v }},
+	}
+	s.Setup()
+}
+
+func (s *Settings) optionText(opt settingsOption) string {
+	return tr(opt.key) + ":" + tr(opt.get())
+}
+
+func (s *Settings) Setup() {
+	if len(s.btnInstances) > 0 {
+		s.Destroy()
+		s.btnInstances = nil
+	}
+	h := StatusLineHeight
+	var widest int32
+	for _, opt := range s.options {
+		for _, v := range opt.values {
+			if w := textWidth(tr(opt.key)+":"+tr(v), StatusLineFontSize); w > widest {
+				widest = w
+			}
+		}
+	}
+	arrowW := h * ((widest+h-1)/h + 2)
+	w, ht := arrowW+h*2, h*int32(len(s.options)+3)
+	s.rect = sdl.Rect{(WinWidth - w) / 2, (WinHeight - ht) / 2, w, ht}
+	title := &Label{}
+	title.Setup(sdl.Point{s.rect.X + h, s.rect.Y + 2}, tr("settings"), StatusLineFontSize, ForegroundStatusLine)
+	s.btnInstances = append(s.btnInstances, title)
+	for i, opt := range s.options {
+		arrow := &Arrow{}
+		arrow.New(sdl.Rect{s.rect.X + h, s.rect.Y + h*int32(i+1), arrowW, h}, s.optionText(opt), BackgroundStatusLine, ForegroundStatusLine, StatusLineFontSize)
+		s.btnInstances = append(s.btnInstances, arrow)
+	}
+	btn := &Button{}
+	btn.Setup(sdl.Rect{(s.rect.W - h*4) / 2, s.rect.H - h - h/2, h * 4, h}, sdl.Point{s.rect.X, s.rect.Y}, tr("ok"), StatusLineFontSize, BackgroundStatusLine, ForegroundStatusLine)
+	s.btnInstances = append(s.btnInstances, btn)
+}
+
+func (s *Settings) IsVisible() bool {
+	return s.visible
+}
+
+// Следующее или предыдущее значение по кругу
+func (s *Settings) cycle(idx, step int) {
+	opt := s.options[idx]
+	current, n := opt.get(), len(opt.values)
+	next := 0
+	for i, v := range opt.values {
+		if v == current {
+			next = (i + step + n) % n
+		}
+	}
+	opt.set(opt.values[next])
+	s.btnInstances[idx+1].(*Arrow).SetLabel(s.optionText(opt))
+	logUI.Info("settings", opt.key, opt.values[next])
+	saveConfig()
+}
+
+func (s *Settings) Update(event Message) {
+	switch event.Kind() {
+	case SettingsToggleEvent:
+		s.visible = !s.visible
+	case SettingsChangedEvent, WindowResized:
+		s.Setup()
+	}
+	if !s.visible {
+		return
+	}
+	for _, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			button.(*Button).Update()
+		case *Arrow:
+			button.(*Arrow).Update(event.Kind())
+		}
+	}
+}
+
+func (s *Settings) Render(renderer *sdl.Renderer) {
+	if !s.visible {
+		return
+	}
+	renderer.SetDrawColor(BackgroundStatusLine.R, BackgroundStatusLine.G, BackgroundStatusLine.B, BackgroundStatusLine.A)
+	renderer.FillRect(&s.rect)
+	renderer.SetDrawColor(ForegroundStatusLine.R, ForegroundStatusLine.G, ForegroundStatusLine.B, ForegroundStatusLine.A)
+	renderer.DrawRect(&s.rect)
+	for _, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			button.(*Button).Render(renderer)
+		case *Arrow:
+			button.(*Arrow).Render(renderer)
+		case *Label:
+			button.(*Label).Render(renderer)
+		}
+	}
+}
+
+// Пока окно открыто, нажатия мыши не доходят до поля и строки статуса
+func (s *Settings) Event(event sdl.Event) (e Message) {
+	if !s.visible {
+		return NilEvent
+	}
+	switch event.(type) {
+	case *sdl.MouseButtonEvent:
+		for idx, button := range s.btnInstances {
+			switch button.(type) {
+			case *Button:
+				if ok := button.(*Button).Event(event); ok == MouseButtonLeftReleasedEvent {
+					return SettingsToggleEvent
+				}
+			case *Arrow:
+				switch button.(*Arrow).Event(event) {
+				case IncButtonEvent:
+					s.cycle(idx-1, 1)
+					return SettingsChangedEvent
+				case DecButtonEvent:
+					s.cycle(idx-1, -1)
+					return SettingsChangedEvent
+				}
+			}
+		}
+		return ConsumedEvent
+	}
+	return NilEvent
+}
+
+func (s *Settings) Destroy() {
+	for _, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			button.(*Button).Destroy()
+		case *Arrow:
+			button.(*Arrow).Destroy()
+		case *Label:
+			button.(*Label).Destroy()
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import "sort"
+
+/*
+.oPYo.        8
+8             8
+`Yooo. .oPYo. 8 o    o .oPYo. oPYo.
+    `8 8    8 8 Y.  .P 8oooo8 8  `'
+     8 8    8 8 `b..d' 8.     8
+`YooP' `YooP' 8  `YP'  `Yooo' 8
+:.....::.....:..:.....::.....:..::::
+::::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::::*/
+
+// Значения ячеек для решателя, открытые ячейки хранят свою цифру
+const (
+	solverUnknown int32 = -1
+	solverMine    int32 = -2
+)
+
+type (
+	// Логический решатель: по открытым цифрам находит ячейки, где наверняка мина или пусто
+	Solver struct {
+		row, column, mines int32
+		cells              []int32
+	}
+	// Среди ячеек cells ровно count мин
+	constraint struct {
+		cells []int32
+		count int32
+	}
+)
+
+func NewSolver(row, column, mines int32, cells []int32) *Solver {
+	return &Solver{row: row, column: column, mines: mines, cells: cells}
+}
+
+func (s *Solver) neighbours(idx int32) (cells []int32) {
+	x, y := idx%s.row, idx/s.row
+	for dy := int32(-1); dy < 2; dy++ {
+		for dx := int32(-1); dx < 2; dx++ {
+			nx, ny := x+dx, y+dy
+			if (dx != 0 || dy != 0) && nx >= 0 && nx < s.row && ny >= 0 && ny < s.column {
+				cells = append(cells, ny*s.row+nx)
+			}
+		}
+	}
+	return cells
+}
+
+// Ограничения от каждой открытой цифры с учетом уже найденных мин
+func (s *Solver) constraints(known map[int32]bool) (cs []constraint) {
+	for idx, value := range s.cells {
+		if value < 0 {
+			continue
+		}
+		c := constraint{count: value}
+		for _, n := range s.neighbours(int32(idx)) {
+			switch s.cells[n] {
+			case solverMine:
+				c.count--
+			case solverUnknown:
+				if mine, ok := known[n]; !ok {
+					c.cells = append(c.cells, n)
+				} else if mine {
+					c.count--
+				}
+			}
+		}
+		if len(c.cells) > 0 {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+func isSubset(a, b []int32) bool {
+	set := map[int32]bool{}
+	for _, c := range b {
+		set[c] = true
+	}
+	for _, c := range a {
+		if !set[c] {
+			return false
+		}
+	}
+	return true
+}
+
+func difference(b, a []int32) (cells []int32) {
+	set := map[int32]bool{}
+	for _, c := range a {
+		set[c] = true
+	}
+	for _, c := range b {
+		if !set[c] {
+			cells = append(cells, c)
+		}
+	}
+	return cells
+}
+
+// Правила по очереди: одна цифра, пара вложенных цифр, общее число мин. Повторяет, пока находит новое
+func (s *Solver) Deduce() (safe, mines []int32) {
+	known := map[int32]bool{}
+	changed := true
+	mark := func(cells []int32, mine bool) {
+		for _, c := range cells {
+			if _, ok := known[c]; !ok {
+				known[c] = mine
+				changed = true
+			}
+		}
+	}
+	for changed {
+		changed = false
+		cs := s.constraints(known)
+		for _, c := range cs {
+			if c.count == 0 {
+				mark(c.cells, false)
+			} else if c.count == int32(len(c.cells)) {
+				mark(c.cells, true)
+			}
+		}
+		if changed {
+			continue
+		}
+		for i, a := range cs {
+			for j, b := range cs {
+				if i == j || len(a.cells) >= len(b.cells) || !isSubset(a.cells, b.cells) {
+					continue
+				}
+				diff, count := difference(b.cells, a.cells), b.count-a.count
+				if count == 0 {
+					mark(diff, false)
+				} else if count == int32(len(diff)) {
+					mark(diff, true)
+				}
+			}
+		}
+		if changed || s.mines <= 0 {
+			continue
+		}
+		var unknown []int32
+		left := s.mines
+		for idx, value := range s.cells {
+			if mine, ok := known[int32(idx)]; value == solverMine || ok && mine {
+				left--
+			} else if value == solverUnknown && !ok {
+				unknown = append(unknown, int32(idx))
+			}
+		}
+		if left == 0 {
+			mark(unknown, false)
+		} else if left == int32(len(unknown)) {
+			mark(unknown, true)
+		}
+	}
+	for idx, mine := range known {
+		if mine {
+			mines = append(mines, idx)
+		} else {
+			safe = append(safe, idx)
+		}
+	}
+	sort.Slice(safe, func(i, j int) bool { return safe[i] < safe[j] })
+	sort.Slice(mines, func(i, j int) bool { return mines[i] < mines[j] })
+	return safe, mines
+}
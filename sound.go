@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+
+	"github.com/veandco/go-sdl2/mix"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+.oPYo.                          8
+8                               8
+`Yooo. .oPYo. o    o odYo. .oPYo8
+    `8 8    8 8    8 8' `8 8    8
+     8 8    8 8    8 8   8 8    8
+`YooP' `YooP' `YooP' 8   8 `YooP'
+:.....::.....::.....:..::..:.....:
+::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::*/
+
+type (
+	// Звук: имя файла замены, событие, ноты по очереди или шум
+	soundSpec struct {
+		name   string
+		event  Event
+		notes  []float64
+		length float64
+		noise  bool
+	}
+	// Наблюдатель звуки, без звукового устройства молчит
+	Sound struct {
+		chunks  map[Event]*mix.Chunk
+		enabled bool
+	}
+)
+
+const soundRate = 22050
+
+var (
+	soundSpecs = []soundSpec{
+		{name: "open", event: CellOpenedEvent, notes: []float64{1200}, length: 0.03},
+		{name: "cascade", event: CascadeEvent, notes: []float64{600, 900, 1200}, length: 0.04},
+		{name: "flag", event: FlagChangedEvent, notes: []float64{800}, length: 0.05},
+		{name: "chord", event: ChordOpenedEvent, notes: []float64{700, 1000}, length: 0.04},
+		{name: "explosion", event: ExplosionEvent, length: 0.6, noise: true},
+		{name: "victory", event: VictoryEvent, notes: []float64{523, 659, 784, 1047}, length: 0.12},
+	}
+)
+
+// Моно 16 бит: синус каждой ноты или шум, громкость спадает к концу звука
+func synthesize(spec soundSpec) (samples []int16) {
+	rnd := rand.New(rand.NewSource(1))
+	n := int(spec.length * soundRate)
+	if spec.noise {
+		for i := 0; i < n; i++ {
+			fade := 1 - float64(i)/float64(n)
+			samples = append(samples, int16((rnd.Float64()*2-1)*fade*fade*math.MaxInt16/2))
+		}
+		return samples
+	}
+	for _, freq := range spec.notes {
+		for i := 0; i < n; i++ {
+			fade := 1 - float64(i)/float64(n)
+			samples = append(samples, int16(math.Sin(2*math.Pi*freq*float64(i)/soundRate)*fade*math.MaxInt16/3))
+		}
+	}
+	return samples
+}
+
+// Заголовок RIFF, чтобы микшер сам перевел звук в формат устройства
+func wavBytes(samples []int16) []byte {
+	buf := &bytes.Buffer{}
+	size := uint32(len(samples) * 2)
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, 36+size)
+	buf.WriteString("WAVEfmt ")
+	// размер блока, PCM, моно, частота, байт в секунду, байт на отсчет, бит на отсчет
+	for _, v := range []interface{}{uint32(16), uint16(1), uint16(1), uint32(soundRate), uint32(soundRate * 2), uint16(2), uint16(16)} {
+		binary.Write(buf, binary.LittleEndian, v)
+	}
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, size)
+	binary.Write(buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+// Файл sounds/<имя>.wav из ресурсов заменяет синтезированный звук
+func loadSound(spec soundSpec) (*mix.Chunk, error) {
+	if data, err := loadAsset("sounds/" + spec.name + ".wav"); err == nil {
+		if rw, err := sdl.RWFromMem(data); err == nil {
+			if chunk, err := mix.LoadWAVRW(rw, true); err == nil {
+				return chunk, nil
+			}
+		}
+		logUI.Warn("sound file", "name", spec.name)
+	}
+	rw, err := sdl.RWFromMem(wavBytes(synthesize(spec)))
+	if err != nil {
+		return nil, err
+	}
+	return mix.LoadWAVRW(rw, true)
+}
+
+func (s *Sound) New() {
+	s.chunks = map[Event]*mix.Chunk{}
+	if err := sdl.InitSubSystem(sdl.INIT_AUDIO); err != nil {
+		logUI.Warn("sound off", "err", err)
+		return
+	}
+	if err := mix.OpenAudio(mix.DEFAULT_FREQUENCY, mix.DEFAULT_FORMAT, mix.DEFAULT_CHANNELS, mix.DEFAULT_CHUNKSIZE); err != nil {
+		logUI.Warn("sound off", "err", err)
+		return
+	}
+	for _, spec := range soundSpecs {
+		chunk, err := loadSound(spec)
+		if err != nil {
+			logUI.Warn("sound", "name", spec.name, "err", err)
+			continue
+		}
+		s.chunks[spec.event] = chunk
+	}
+	s.enabled = true
+	s.Setup()
+}
+
+func (s *Sound) Setup() {
+	if s.enabled {
+		mix.Volume(-1, config.Volume*mix.MAX_VOLUME/100)
+	}
+}
+
+func (s *Sound) Update(event Message) {
+	switch event.Kind() {
+	case SettingsChangedEvent:
+		s.Setup()
+		return
+	}
+	if chunk, ok := s.chunks[event.Kind()]; ok && s.enabled && config.Sound {
+		if _, err := chunk.Play(-1, 0); err != nil {
+			logUI.Warn("sound", "err", err)
+		}
+	}
+}
+
+func (s *Sound) Render(renderer *sdl.Renderer) {}
+
+func (s *Sound) Event(event sdl.Event) Message {
+	return NilEvent
+}
+
+func (s *Sound) Destroy() {
+	for _, chunk := range s.chunks {
+		chunk.Free()
+	}
+	if s.enabled {
+		mix.CloseAudio()
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+.oPYo. o          o    o        o    o
+8       8          8             8
+`Yooo. o8P .oPYo. o8P o8 .oPYo. o8P o8 .oPYo. .oPYo.
+    `8  8  .oooo8  8   8 Yb..    8   8 8    ' Yb..
+     8  8  8    8  8   8   'Yb.  8   8 8    .   'Yb.
+`YooP'  8  `YooP8  8   8 `YooP'  8   8 `YooP' `YooP'
+:.....::..::.....::..::..:.....::..::..:.....::.....:
+:::::::::::::::::::::::::::::::::::::::::::::::::::::
+:::::::::::::::::::::::::::::::::::::::::::::::::::::*/
+
+type (
+	// Итог одной партии
+	GameRecord struct {
+		Date   time.Time `json:"date"`
+		Preset string    `json:"preset"`
+		Won    bool      `json:"won"`
+		Time   float64   `json:"time"`
+		BBBV   int32     `json:"bbbv"`
+		Clicks int32     `json:"clicks"`
+	}
+	// Партии одного игрока по порядку
+	PlayerStats struct {
+		Games []GameRecord `json:"games"`
+	}
+	// Хранилище статистики всех игроков
+	Stats struct {
+		path    string
+		Players map[string]*PlayerStats `json:"players"`
+	}
+	// Сводка по уровню сложности
+	PresetSummary struct {
+		Played, Won         int
+		BestTime, AvgTime   float64
+		BestBBBVs, AvgBBBVs float64
+		Efficiency          float64
+		Histogram           []int
+		HistogramStep       float64
+	}
+	// Наблюдатель окно статистики
+	StatsBoard struct {
+		rect         sdl.Rect
+		stats        *Stats
+		preset       int
+		histogram    PresetSummary
+		btnInstances []interface{}
+		visible      bool
+	}
+)
+
+var statsPresets = []string{"beginner", "intermediate", "expert", "custom"}
+
+const histogramBuckets = 10
+
+// $XDG_DATA_HOME/mines или ~/.local/share/mines
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "mines")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", "mines")
+}
+
+// Имя игрока из настроек или из окружения
+func playerName() string {
+	if config.Player != "" {
+		return config.Player
+	}
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return "player"
+}
+
+// Уровень сложности по размеру поля
+func presetName(b boardConfig) string {
+	for name, p := range presets {
+		if p.row == b.row && p.column == b.column && p.mines == b.mines {
+			return name
+		}
+	}
+	return "custom"
+}
+
+func (s *Stats) Load(path string) error {
+	s.path = path
+	s.Players = map[string]*PlayerStats{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, s)
+}
+
+func (s *Stats) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+func (s *Stats) Player(name string) *PlayerStats {
+	if s.Players == nil {
+		s.Players = map[string]*PlayerStats{}
+	}
+	if _, ok := s.Players[name]; !ok {
+		s.Players[name] = &PlayerStats{}
+	}
+	return s.Players[name]
+}
+
+// Добавить партию и сразу записать файл
+func (s *Stats) Record(name string, record GameRecord) {
+	p := s.Player(name)
+	p.Games = append(p.Games, record)
+	if err := s.Save(); err != nil {
+		logEngine.Error("stats save", "err", err)
+	}
+}
+
+func (p *PlayerStats) Totals() (played, won int) {
+	for _, g := range p.Games {
+		played++
+		if g.Won {
+			won++
+		}
+	}
+	return played, won
+}
+
+// Текущая серия побед и лучшая серия
+func (p *PlayerStats) Streaks() (current, best int) {
+	for _, g := range p.Games {
+		if g.Won {
+			current++
+			if current > best {
+				best = current
+			}
+		} else {
+			current = 0
+		}
+	}
+	return current, best
+}
+
+// Время, 3BV/s и эффективность считаются только по победам
+func (p *PlayerStats) Summary(preset string) (sum PresetSummary) {
+	var times, bbbvs, effs []float64
+	for _, g := range p.Games {
+		if g.Preset != preset {
+			continue
+		}
+		sum.Played++
+		if !g.Won {
+			continue
+		}
+		sum.Won++
+		times = append(times, g.Time)
+		if g.BBBV > 0 && g.Time > 0 {
+			bbbvs = append(bbbvs, float64(g.BBBV)/g.Time)
+		}
+		if g.BBBV > 0 && g.Clicks > 0 {
+			effs = append(effs, float64(g.BBBV)*100/float64(g.Clicks))
+		}
+	}
+	sum.BestTime, sum.AvgTime = minAvg(times)
+	_, sum.AvgBBBVs = minAvg(bbbvs)
+	sum.BestBBBVs = maxOf(bbbvs)
+	_, sum.Efficiency = minAvg(effs)
+	sum.Histogram = make([]int, histogramBuckets)
+	if max := maxOf(times); max > 0 {
+		sum.HistogramStep = max / histogramBuckets
+		for _, t := range times {
+			bucket := int(t / sum.HistogramStep)
+			if bucket >= histogramBuckets {
+				bucket = histogramBuckets - 1
+			}
+			sum.Histogram[bucket]++
+		}
+	}
+	return sum
+}
+
+func minAvg(values []float64) (min, avg float64) {
+	for i, v := range values {
+		if i == 0 || v < min {
+			min = v
+		}
+		avg += v
+	}
+	if len(values) > 0 {
+		avg /= float64(len(values))
+	}
+	return min, avg
+}
+
+func maxOf(values []float64) (max float64) {
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+/*
+.oPYo. o          o          .oPYo.                           8
+8       8          8          8   `8                          8
+`Yooo. o8P .oPYo. o8P .oPYo. o8YooP' .oPYo. .oPYo. oPYo. .oPYo8
+    `8  8  .oooo8  8  Yb..    8   `b 8    8 .oooo8 8  `' 8    8
+     8  8  8    8  8    'Yb.  8    8 8    8 8    8 8     8    8
+`YooP'  8  `YooP8  8  `YooP'  8oooP' `YooP' `YooP8 8     `YooP'
+:.....::..::.....::..::.....::......::.....::.....:..:::::.....:
+::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::
+::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::::*/
+
+func (s *StatsBoard) New(stats *Stats) {
+	s.stats = stats
+	s.Setup()
+}
+
+func (s *StatsBoard) lines() (lines []string) {
+	p := s.stats.Player(playerName())
+	played, won := p.Totals()
+	current, best := p.Streaks()
+	percent := 0
+	if played > 0 {
+		percent = won * 100 / played
+	}
+	sum := p.Summary(statsPresets[s.preset])
+	s.histogram = sum
+	lines = append(lines,
+		fmt.Sprintf(tr("stats_player"), playerName()),
+		fmt.Sprintf(tr("stats_games"), played, won, percent),
+		fmt.Sprintf(tr("stats_streaks"), current, best),
+		fmt.Sprintf(tr("stats_preset"), sum.Played, sum.Won),
+		fmt.Sprintf(tr("stats_times"), sum.BestTime, sum.AvgTime),
+		fmt.Sprintf(tr("stats_bbbvs"), sum.BestBBBVs, sum.AvgBBBVs),
+		fmt.Sprintf(tr("stats_efficiency"), sum.Efficiency))
+	return lines
+}
+
+func (s *StatsBoard) Setup() {
+	if len(s.btnInstances) > 0 {
+		s.Destroy()
+		s.btnInstances = nil
+	}
+	h := StatusLineHeight
+	lines := s.lines()
+	w, ht := WinWidth*2/3, h*int32(len(lines)+8)
+	s.rect = sdl.Rect{(WinWidth - w) / 2, (WinHeight - ht) / 2, w, ht}
+	title := &Label{}
+	title.Setup(sdl.Point{s.rect.X + h, s.rect.Y + 2}, tr("statistics"), StatusLineFontSize, ForegroundStatusLine)
+	s.btnInstances = append(s.btnInstances, title)
+	arrow := &Arrow{}
+	text := tr("preset") + ":" + tr(statsPresets[s.preset])
+	arrow.New(sdl.Rect{s.rect.X + h, s.rect.Y + h, h * ((textWidth(tr("preset")+":"+tr("intermediate"), StatusLineFontSize)+h-1)/h + 2), h}, text, BackgroundStatusLine, ForegroundStatusLine, StatusLineFontSize)
+	s.btnInstances = append(s.btnInstances, arrow)
+	for i, line := range lines {
+		label := &Label{}
+		label.Setup(sdl.Point{s.rect.X + h, s.rect.Y + h*int32(i+2)}, line, StatusLineFontSize, ForegroundStatusLine)
+		s.btnInstances = append(s.btnInstances, label)
+	}
+	btn := &Button{}
+	btn.Setup(sdl.Rect{(s.rect.W - h*4) / 2, s.rect.H - h - h/2, h * 4, h}, sdl.Point{s.rect.X, s.rect.Y}, tr("ok"), StatusLineFontSize, BackgroundStatusLine, ForegroundStatusLine)
+	s.btnInstances = append(s.btnInstances, btn)
+}
+
+func (s *StatsBoard) Update(event Message) {
+	switch event.Kind() {
+	case StatsToggleEvent:
+		if s.visible = !s.visible; s.visible {
+			s.Setup()
+		}
+	case SettingsChangedEvent, WindowResized, GameEndEvent:
+		s.Setup()
+	}
+	if !s.visible {
+		return
+	}
+	for _, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			button.(*Button).Update()
+		case *Arrow:
+			button.(*Arrow).Update(event.Kind())
+		}
+	}
+}
+
+// Гистограмма времени побед столбиками под строками сводки
+func (s *StatsBoard) renderHistogram(renderer *sdl.Renderer) {
+	h := StatusLineHeight
+	var most int
+	for _, n := range s.histogram.Histogram {
+		if n > most {
+			most = n
+		}
+	}
+	if most == 0 {
+		return
+	}
+	x0, y0 := s.rect.X+h, s.rect.Y+s.rect.H-h*2
+	barW := (s.rect.W - h*2) / histogramBuckets
+	renderer.SetDrawColor(ForegroundStatusLine.R, ForegroundStatusLine.G, ForegroundStatusLine.B, ForegroundStatusLine.A)
+	for i, n := range s.histogram.Histogram {
+		barH := int32(n) * h * 3 / int32(most)
+		renderer.FillRect(&sdl.Rect{x0 + int32(i)*barW + 1, y0 - barH, barW - 2, barH})
+	}
+}
+
+func (s *StatsBoard) Render(renderer *sdl.Renderer) {
+	if !s.visible {
+		return
+	}
+	renderer.SetDrawColor(BackgroundStatusLine.R, BackgroundStatusLine.G, BackgroundStatusLine.B, BackgroundStatusLine.A)
+	renderer.FillRect(&s.rect)
+	renderer.SetDrawColor(ForegroundStatusLine.R, ForegroundStatusLine.G, ForegroundStatusLine.B, ForegroundStatusLine.A)
+	renderer.DrawRect(&s.rect)
+	for _, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			button.(*Button).Render(renderer)
+		case *Arrow:
+			button.(*Arrow).Render(renderer)
+		case *Label:
+			button.(*Label).Render(renderer)
+		}
+	}
+	s.renderHistogram(renderer)
+}
+
+func (s *StatsBoard) Event(event sdl.Event) (e Message) {
+	if !s.visible {
+		return NilEvent
+	}
+	switch event.(type) {
+	case *sdl.MouseButtonEvent:
+		for _, button := range s.btnInstances {
+			switch button.(type) {
+			case *Button:
+				if ok := button.(*Button).Event(event); ok == MouseButtonLeftReleasedEvent {
+					return StatsToggleEvent
+				}
+			case *Arrow:
+				switch button.(*Arrow).Event(event) {
+				case IncButtonEvent:
+					s.preset = (s.preset + 1) % len(statsPresets)
+					s.Setup()
+					return ConsumedEvent
+				case DecButtonEvent:
+					s.preset = (s.preset - 1 + len(statsPresets)) % len(statsPresets)
+					s.Setup()
+					return ConsumedEvent
+				}
+			}
+		}
+		return ConsumedEvent
+	}
+	return NilEvent
+}
+
+func (s *StatsBoard) Destroy() {
+	for _, button := range s.btnInstances {
+		switch button.(type) {
+		case *Button:
+			button.(*Button).Destroy()
+		case *Arrow:
+			button.(*Arrow).Destroy()
+		case *Label:
+			button.(*Label).Destroy()
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"image"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+var update = flag.Bool("update", false, "rewrite golden images in testdata/golden")
+
+var (
+	sdlOnce sync.Once
+	sdlErr  error
+)
+
+// Окно без окна: SDL с пустым видеодрайвером, кадр рисует программный рендерер в память,
+// мышь стоит там, куда ее поставил тест
+type headless struct {
+	t        *testing.T
+	surface  *sdl.Surface
+	renderer *sdl.Renderer
+	mouse    sdl.Point
+}
+
+func newHeadless(t *testing.T) *headless {
+	t.Helper()
+	sdlOnce.Do(func() {
+		os.Setenv("SDL_VIDEODRIVER", "dummy")
+		if sdlErr = sdl.Init(sdl.INIT_VIDEO | sdl.INIT_EVENTS); sdlErr == nil {
+			sdlErr = ttf.Init()
+		}
+	})
+	if sdlErr != nil {
+		t.Skip("no SDL:", sdlErr)
+	}
+	// одинаковые настройки на любой машине, после теста прежние
+	saved := config
+	config = defaultConfig()
+	config.Language = "en"
+	applyConfig()
+	h := &headless{t: t, mouse: sdl.Point{-1, -1}}
+	var err error
+	if h.surface, h.renderer, err = offscreen(WinWidth, WinHeight); err != nil {
+		t.Fatal(err)
+	}
+	mouseState = func() (int32, int32, uint32) {
+		return h.mouse.X, h.mouse.Y, 0
+	}
+	t.Cleanup(func() {
+		mouseState = sdl.GetMouseState
+		h.renderer.Destroy()
+		h.surface.Free()
+		config = saved
+		applyConfig()
+	})
+	return h
+}
+
+// Кадр как в View.Render
+func (h *headless) frame(layers ...Observers) *image.RGBA {
+	h.renderer.SetDrawColor(Background.R, Background.G, Background.B, Background.A)
+	h.renderer.Clear()
+	for _, layer := range layers {
+		layer.Render(h.renderer)
+	}
+	return surfaceImage(h.surface)
+}
+
+func (h *headless) button(o Observers, button, state uint8, x, y int32) Message {
+	h.mouse = sdl.Point{x, y}
+	kind := uint32(sdl.MOUSEBUTTONDOWN)
+	if state == sdl.RELEASED {
+		kind = sdl.MOUSEBUTTONUP
+	}
+	return o.Event(&sdl.MouseButtonEvent{Type: kind, Button: button, State: state, X: x, Y: y})
+}
+
+// Нажать и отпустить кнопку мыши в точке, сообщения слоя на оба события
+func (h *headless) click(o Observers, button uint8, x, y int32) (messages []Message) {
+	for _, state := range []uint8{sdl.PRESSED, sdl.RELEASED} {
+		if msg := h.button(o, button, state, x, y); msg != NilEvent {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+func center(rect *sdl.Rect) (int32, int32) {
+	return rect.X + rect.W/2, rect.Y + rect.H/2
+}
+
+// Пиксели, отличающиеся по какому-нибудь каналу больше чем на 16, разные размеры отличаются целиком
+func imageDiff(a, b *image.RGBA) (diff int) {
+	if a.Bounds() != b.Bounds() {
+		return len(a.Pix) / 4
+	}
+	for i := 0; i < len(a.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			if d := int(a.Pix[i+c]) - int(b.Pix[i+c]); d > 16 || d < -16 {
+				diff++
+				break
+			}
+		}
+	}
+	return diff
+}
+
+func loadPNG(path string) (*image.RGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			rgba.Set(x, y, img.At(x, y))
+		}
+	}
+	return rgba, nil
+}
+
+// Сверить кадр с testdata/golden/name.png, с -update переписать образец, без образца тест падает.
+// Сглаживание шрифта на разных машинах дает немного разных пикселей, до 0.5% прощается
+func (h *headless) golden(name string, img *image.RGBA) {
+	h.t.Helper()
+	path := filepath.Join("testdata", "golden", name+".png")
+	if *update {
+		if err := savePNG(path, img); err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+	want, err := loadPNG(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		h.t.Fatalf("no golden %v, run go test -run %v -update", path, h.t.Name())
+	} else if err != nil {
+		h.t.Fatal(err)
+	}
+	if diff := imageDiff(img, want); diff > len(img.Pix)/4/200 {
+		got := filepath.Join(os.TempDir(), "mines-"+name+".png")
+		savePNG(got, img)
+		h.t.Errorf("%v: %v pixels differ, got %v", path, diff, got)
+	}
+}
+
+// Поле на уровне с открытой областью из середины
+func sampleBoard(size boardConfig) *Field {
+	field := &Field{}
+	field.New(size)
+	field.SetSeed(3)
+	field.SetFirstClick("opening")
+	start := size.column/2*size.row + size.row/2
+	field.Setup(start)
+	pos, _ := field.getPosOfCell(start)
+	field.Open(pos.X, pos.Y)
+	field.MarkFlag(0)
+	return field
+}
+
+func TestGameBoardLayout(t *testing.T) {
+	for _, name := range []string{"beginner", "intermediate", "expert"} {
+		t.Run(name, func(t *testing.T) {
+			h := newHeadless(t)
+			size := presets[name]
+			board := &GameBoard{}
+			board.New(size, true)
+			defer board.Destroy()
+			var cells []*sdl.Rect
+			for idx := int32(0); idx < size.row*size.column; idx++ {
+				rect := board.CellRect(idx)
+				if rect == nil {
+					t.Fatalf("no cell %v", idx)
+				}
+				if rect.X < 0 || rect.Y < StatusLineHeight || rect.X+rect.W > WinWidth || rect.Y+rect.H > WinHeight-StatusLineHeight {
+					t.Errorf("cell %v %v is outside the board area", idx, rect)
+				}
+				if rect.W <= 0 || rect.H <= 0 {
+					t.Errorf("cell %v %v is empty", idx, rect)
+				}
+				for other, prev := range cells {
+					if _, ok := rect.Intersect(prev); ok {
+						t.Fatalf("cell %v %v overlaps cell %v %v", idx, rect, other, prev)
+					}
+				}
+				cells = append(cells, rect)
+			}
+			field := sampleBoard(size)
+			board.SetBoard(field.GetFieldValues(), field.GetStatistic())
+			h.golden("board-"+name, h.frame(board))
+		})
+	}
+}
+
+func TestAnimationFollowsField(t *testing.T) {
+	h := newHeadless(t)
+	config.Animation = "slow"
+	size := presets["beginner"]
+	board := &GameBoard{}
+	board.New(size, true)
+	defer board.Destroy()
+	clock := &fakeClock{t: time.Unix(1e9, 0)}
+	animation := &Animation{clock: clock.now}
+	animation.New(board)
+	field := sampleBoard(size)
+	values, stat := field.GetFieldValues(), field.GetStatistic()
+	waves := map[int32]int32{}
+	for i, idx := range openedCells(field) {
+		waves[idx] = int32(i)
+	}
+	cascade := OpenEvent{CascadeEvent, waves}
+	for _, msg := range []Message{cascade, FieldEvent{FieldChangedEvent, values, stat, size, nil, false}} {
+		board.Update(msg)
+		animation.Update(msg)
+	}
+	if len(animation.opening) != len(waves) {
+		t.Fatalf("move on the same field stops the cascade: %v cells", len(animation.opening))
+	}
+	// первый кадр после обновления поля отличается от следующих
+	h.frame(board)
+	if imageDiff(h.frame(board, animation), h.frame(board)) == 0 {
+		t.Fatal("cascade is not drawn")
+	}
+	field.SetState(gamePause)
+	paused := FieldEvent{FieldChangedEvent, field.GetFieldValues(), stat, size, nil, false}
+	board.Update(paused)
+	animation.Update(paused)
+	h.frame(board)
+	if imageDiff(h.frame(board, animation), h.frame(board)) != 0 {
+		t.Error("cascade is drawn over the pause")
+	}
+	fresh := FieldEvent{FieldChangedEvent, values, stat, size, nil, true}
+	board.Update(fresh)
+	animation.Update(fresh)
+	if len(animation.opening) != 0 {
+		t.Errorf("cascade of the old field plays on the new one: %v cells", len(animation.opening))
+	}
+}
+
+func TestGameBoardMouse(t *testing.T) {
+	h := newHeadless(t)
+	board := &GameBoard{}
+	board.New(presets["beginner"], true)
+	defer board.Destroy()
+	x, y := center(board.CellRect(10))
+	tests := []struct {
+		button uint8
+		want   Message
+	}{
+		{sdl.BUTTON_LEFT, CellEvent{MouseButtonLeftReleasedEvent, 10}},
+		{sdl.BUTTON_RIGHT, CellEvent{MouseButtonRightReleasedEvent, 10}},
+		{sdl.BUTTON_MIDDLE, CellEvent{ChordEvent, 10}},
+	}
+	for _, tt := range tests {
+		if got := h.click(board, tt.button, x, y); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("button %v: %v, want %v", tt.button, got, tt.want)
+		}
+	}
+	// аккорд обеими кнопками срабатывает на первом отпускании, второе молчит
+	h.button(board, sdl.BUTTON_LEFT, sdl.PRESSED, x, y)
+	h.button(board, sdl.BUTTON_RIGHT, sdl.PRESSED, x, y)
+	if got := h.button(board, sdl.BUTTON_LEFT, sdl.RELEASED, x, y); got != (CellEvent{ChordEvent, 10}) {
+		t.Errorf("both buttons: %v, want chord", got)
+	}
+	if got := h.button(board, sdl.BUTTON_RIGHT, sdl.RELEASED, x, y); got != NilEvent {
+		t.Errorf("second release after chord: %v", got)
+	}
+	if got := h.click(board, sdl.BUTTON_LEFT, -5, -5); len(got) != 0 {
+		t.Errorf("click outside the board: %v", got)
+	}
+}
+
+func TestStatusLineArrows(t *testing.T) {
+	h := newHeadless(t)
+	size := presets["beginner"]
+	size.minesPercent = size.mines * 100 / (size.row * size.column)
+	statusLine := &StatusLine{}
+	statusLine.New(size)
+	defer statusLine.Destroy()
+	inc := statusLine.btnInstances[4].(*Arrow).btnInstances[2].(*Button)
+	got := h.click(statusLine, sdl.BUTTON_LEFT, inc.GetRect().X+1, inc.GetRect().Y+1)
+	if len(got) != 1 || got[0].Kind() != IncRowEvent || got[0].(BoardEvent).Size.row != size.row+1 {
+		t.Fatalf("row arrow: %v", got)
+	}
+	x, y := center(statusLine.btnInstances[6].(*Arrow).btnInstances[0].(*Button).GetRect())
+	got = h.click(statusLine, sdl.BUTTON_LEFT, x, y)
+	if len(got) != 1 || got[0].Kind() != DecMinesEvent || got[0].(BoardEvent).Size.mines != size.mines-1 {
+		t.Fatalf("mines arrow: %v", got)
+	}
+	x, y = center(statusLine.btnInstances[3].(*Button).GetRect())
+	got = h.click(statusLine, sdl.BUTTON_LEFT, x, y)
+	want := boardConfig{row: size.row + 1, column: size.column, mines: size.mines - 1}
+	if len(got) != 1 || got[0].Kind() != NewGameEvent || got[0].(BoardEvent).Size.row != want.row || got[0].(BoardEvent).Size.mines != want.mines {
+		t.Fatalf("new game: %v, want size %v", got, want)
+	}
+	editor := statusLine.Event(&sdl.KeyboardEvent{Type: sdl.KEYUP, State: sdl.RELEASED, Keysym: sdl.Keysym{Sym: sdl.K_e}})
+	if board, ok := editor.(BoardEvent); !ok || board.Kind() != EditorToggleEvent || board.Size.row != want.row {
+		t.Fatalf("editor key: %v, want size %v", editor, want)
+	}
+	h.mouse = sdl.Point{-1, -1}
+	statusLine.Update(TickEvent)
+	h.golden("statusline", h.frame(statusLine))
+}
+
+// Мин всегда меньше, чем ячеек, и после уменьшения поля тоже
+func TestStatusLineMinesLimit(t *testing.T) {
+	newHeadless(t)
+	size := boardConfig{row: minRow + 1, column: minColumn, mines: (minRow+1)*minColumn - 1}
+	statusLine := &StatusLine{}
+	statusLine.New(size)
+	defer statusLine.Destroy()
+	mines := statusLine.btnInstances[6].(*Arrow)
+	if err := statusLine.calc(buttonMines, mines, "inc"); err != nil {
+		t.Fatal(err)
+	}
+	if got := statusLine.GetGameBoardSize().mines; got != size.mines {
+		t.Errorf("mines %v after inc on a full board, want %v", got, size.mines)
+	}
+	if err := statusLine.calc(buttonRow, statusLine.btnInstances[4].(*Arrow), "dec"); err != nil {
+		t.Fatal(err)
+	}
+	want := int32(minRow*minColumn - 1)
+	if got := statusLine.GetGameBoardSize().mines; got != want {
+		t.Errorf("mines %v after the board shrank, want %v", got, want)
+	}
+	if n, err := mines.GetNumber(); err != nil || int32(n[0]) != want {
+		t.Errorf("mines arrow shows %v: %v", n, err)
+	}
+}
+
+// На паузе клавиши только снимают паузу
+func TestPausedKeysOnlyResume(t *testing.T) {
+	key := func(sym sdl.Keycode, state uint8) *sdl.KeyboardEvent {
+		return &sdl.KeyboardEvent{Type: sdl.KEYUP, State: state, Keysym: sdl.Keysym{Sym: sym}}
+	}
+	v := &View{paused: true}
+	for _, sym := range []sdl.Keycode{sdl.K_d, sdl.K_p, sdl.K_e, sdl.K_s, sdl.K_x, sdl.K_c, sdl.K_g, sdl.K_SPACE} {
+		var events []Message
+		v.event = key(sym, sdl.PRESSED)
+		if !v.input(&events) || len(events) != 0 {
+			t.Errorf("key %v pressed on pause: %v", sym, events)
+		}
+		v.event = key(sym, sdl.RELEASED)
+		if !v.input(&events) || len(events) != 1 || events[0] != AnyKeyEvent {
+			t.Errorf("key %v released on pause: %v", sym, events)
+		}
+	}
+	v.paused = false
+	var events []Message
+	v.event = key(sdl.K_d, sdl.RELEASED)
+	if v.input(&events); len(events) != 1 || events[0] != DailyEvent {
+		t.Errorf("D in the game: %v", events)
+	}
+}
+
+func TestButtonFocus(t *testing.T) {
+	h := newHeadless(t)
+	fg, bg := sdl.Color{255, 255, 255, 255}, sdl.Color{0, 0, 128, 255}
+	button := &Button{}
+	button.Setup(sdl.Rect{10, 10, 60, 30}, sdl.Point{5, 5}, " ", StatusLineFontSize, fg, bg)
+	defer button.Destroy()
+	inside := func() (r, g, b uint8) {
+		h.frame()
+		button.Render(h.renderer)
+		c := surfaceImage(h.surface).RGBAAt(20, 20)
+		return c.R, c.G, c.B
+	}
+	button.Update()
+	if r, g, b := inside(); r != bg.R || g != bg.G || b != bg.B {
+		t.Errorf("idle button %v %v %v, want background", r, g, b)
+	}
+	h.mouse = sdl.Point{30, 30}
+	button.Update()
+	if !button.GetFocus() {
+		t.Fatal("no focus under the mouse")
+	}
+	if r, g, b := inside(); r != fg.R || g != fg.G || b != fg.B {
+		t.Errorf("focused button %v %v %v, want inverted colors", r, g, b)
+	}
+}
+
+func TestMessageBox(t *testing.T) {
+	h := newHeadless(t)
+	size := presets["beginner"]
+	board := &GameBoard{}
+	board.New(size, true)
+	defer board.Destroy()
+	field := sampleBoard(size)
+	values := field.GetFieldValues()
+	values[len(values)-1] = won
+	board.SetBoard(values, field.GetStatistic())
+	board.SetSummary([]string{"Time 12.345", "3BV 20/20"})
+	if board.messageBox.Hide {
+		t.Fatal("message box is hidden after the win")
+	}
+	img := h.frame(board)
+	x, y := center(board.messageBox.okButton.GetRect())
+	if got := h.click(board, sdl.BUTTON_LEFT, x, y); len(got) != 0 {
+		t.Errorf("ok button: %v", got)
+	}
+	if !board.messageBox.Hide {
+		t.Error("ok button did not close the message box")
+	}
+	h.golden("message-won", img)
+}